@@ -0,0 +1,94 @@
+package fncmp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// App is an independent fncmp application.
+//
+// An App owns its config, logger, connections, handlers and event listeners,
+// so several apps can run in one process without sharing state. The package
+// level functions delegate to a default App.
+type App struct {
+	config *Config
+	log    leveledLogger
+	conns  conns
+	// pending are conns of server rendered pages waiting for their client
+	pending   conns
+	handlers  handlerPool
+	listeners eventListeners
+	metrics   *metrics
+	inspector *inspector
+	// shuttingDown is set once Shutdown is called and rejects new connections
+	shuttingDown atomic.Bool
+	// workers tracks the goroutines of every listening handler
+	workers sync.WaitGroup
+	// handshakeTimeout bounds how long a client may take to send its handshake
+	handshakeTimeout time.Duration
+}
+
+var defaultApp = NewApp(nil)
+
+// NewApp creates an App with the given config, or the default config if nil
+func NewApp(c *Config) *App {
+	a := &App{
+		config: defaultConfig(),
+		conns: conns{
+			pool: make(map[string]*conn),
+		},
+		pending: conns{
+			pool: make(map[string]*conn),
+		},
+		handlers: handlerPool{
+			pool: make(map[string]handler),
+		},
+		listeners: eventListeners{
+			el: make(map[*conn]map[string]EventListener),
+		},
+		metrics:          newMetrics(),
+		inspector:        newInspector(),
+		handshakeTimeout: handshakeTimeout,
+	}
+	a.configureLogger()
+	if c != nil {
+		a.Configure(c)
+	}
+	return a
+}
+
+// DefaultApp returns the App used by the package level functions
+func DefaultApp() *App {
+	return defaultApp
+}
+
+// Config returns the App's config
+func (a *App) Config() *Config {
+	return a.config
+}
+
+// MiddleWareFn wraps the default App's MiddleWareFn
+func MiddleWareFn(h http.HandlerFunc, hf HandleFn) http.HandlerFunc {
+	return defaultApp.MiddleWareFn(h, hf)
+}
+
+// Shutdown gracefully stops the default App
+func Shutdown(ctx context.Context, notice Component) error {
+	return defaultApp.Shutdown(ctx, notice)
+}
+
+// Queues returns the outbound queue stats of every open connection of the default App
+func Queues() []QueueStats {
+	return defaultApp.Queues()
+}
+
+// app returns the App a dispatch belongs to, or the default App if it has no connection
+func (d *Dispatch) app() *App {
+	if d.conn == nil || d.conn.app == nil {
+		return defaultApp
+	}
+	return d.conn.app
+}
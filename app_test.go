@@ -0,0 +1,57 @@
+package fncmp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppIsolation(t *testing.T) {
+	apps := []*App{
+		NewApp(&Config{Silent: true, SendBuffer: 8}),
+		NewApp(&Config{Silent: true, SendBuffer: 32}),
+	}
+	clients := make([]*testClient, len(apps))
+	for i, app := range apps {
+		h := newTestHandler(t, app)
+		// Both clients choose the same ID
+		tr := newMemTransport()
+		c := app.addConn(tr, httptest.NewRequest(http.MethodGet, "/", nil), h.id, "conn", clickCounter)
+		clients[i] = connectTestClient(t, c, tr)
+		clients[i].nextRender()
+	}
+
+	for i, app := range apps {
+		tc := clients[i]
+		if n := len(app.handlers.All()); n != 1 {
+			t.Errorf("app %d has %d handlers, want 1", i, n)
+		}
+		if c, ok := app.conns.Get("conn"); !ok || c != tc.conn {
+			t.Errorf("app %d does not own its conn", i)
+		}
+		if n := len(app.listeners.List(tc.conn)); n != 1 {
+			t.Errorf("app %d has %d listeners, want 1", i, n)
+		}
+	}
+	if apps[0].Config().SendBuffer == apps[1].Config().SendBuffer {
+		t.Error("apps share their config")
+	}
+	if defaultApp.Config().SendBuffer == apps[0].Config().SendBuffer {
+		t.Error("app changed the default App's config")
+	}
+
+	// A listener of one app cannot be triggered through the other
+	el := clients[0].listener(OnClick)
+	if _, ok := apps[1].listeners.Get(el.ID, clients[1].conn); ok {
+		t.Fatal("listener registered in both apps")
+	}
+	clients[1].fire(el)
+	clients[1].quiet(100 * time.Millisecond)
+
+	clients[0].fire(el)
+	if d := clients[0].nextRender(); !strings.Contains(d.FnRender.HTML, "clicked") {
+		t.Errorf("render = %q, want clicked", d.FnRender.HTML)
+	}
+}
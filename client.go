@@ -0,0 +1,112 @@
+package fncmp
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// clientJS is the compiled client runtime, built from static/assets/index.ts
+//
+//go:embed static/assets/index.js
+var clientJS []byte
+
+// clientHash identifies the embedded client runtime's content
+var clientHash = func() string {
+	sum := sha256.Sum256(clientJS)
+	return hex.EncodeToString(sum[:])[:16]
+}()
+
+// defaultClientPath is the path the App's Handler is expected to be mounted on
+const defaultClientPath = "/_fncmp/"
+
+// clientFile returns the versioned file name of the client runtime
+func clientFile() string {
+	return "client." + clientHash + ".js"
+}
+
+// Handler returns the default App's Handler
+func Handler() http.Handler {
+	return defaultApp.Handler()
+}
+
+// Script returns the default App's Script
+func Script() Component {
+	return defaultApp.Script()
+}
+
+// Handler returns an http.Handler serving the embedded client runtime.
+//
+// It must be mounted on Config.ClientPath, "/_fncmp/" by default:
+//
+//	http.Handle("/_fncmp/", fncmp.Handler())
+//
+// The versioned file named by Script is cached indefinitely, while "client.js"
+// always revalidates so it can be referenced without a hash.
+//
+// In DevMode it also serves an inspector at "debug/", listing live connections
+// and their event listeners, recent dispatches and errors. It can re-fire an
+// event or push a render to a connection, so DevMode must not be enabled in
+// production. Actions require a token embedded in the inspector page, so
+// other pages open in the browser cannot post to them.
+func (a *App) Handler() http.Handler {
+	etag := strconv.Quote(clientHash)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.config.DevMode {
+			if route, ok := a.debugPath(r.URL.Path); ok {
+				a.serveDebug(w, r, route)
+				return
+			}
+		}
+		switch path.Base(r.URL.Path) {
+		case clientFile():
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		case "client.js":
+			w.Header().Set("Cache-Control", "no-cache")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(clientJS)
+	})
+}
+
+// Script returns a component rendering the script tag of the embedded client runtime
+func (a *App) Script() Component {
+	return clientScript{path: a.clientPath() + clientFile()}
+}
+
+func (a *App) clientPath() string {
+	p := a.config.ClientPath
+	if p == "" {
+		p = defaultClientPath
+	}
+	if p[len(p)-1] != '/' {
+		p += "/"
+	}
+	return p
+}
+
+type clientScript struct {
+	path string
+}
+
+func (s clientScript) Render(ctx context.Context, w io.Writer) error {
+	nonce := ""
+	if n := Nonce(ctx); n != "" {
+		nonce = " nonce=\"" + n + "\""
+	}
+	_, err := io.WriteString(w, "<script src=\""+s.path+"\""+nonce+" defer></script>")
+	return err
+}
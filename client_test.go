@@ -0,0 +1,93 @@
+package fncmp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		status       int
+		cacheControl string
+	}{
+		{
+			name:         "versioned",
+			path:         defaultClientPath + clientFile(),
+			status:       http.StatusOK,
+			cacheControl: "public, max-age=31536000, immutable",
+		},
+		{
+			name:         "unversioned",
+			path:         defaultClientPath + "client.js",
+			status:       http.StatusOK,
+			cacheControl: "no-cache",
+		},
+		{
+			name:   "stale version",
+			path:   defaultClientPath + "client.0000000000000000.js",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "other file",
+			path:   defaultClientPath + "index.ts",
+			status: http.StatusNotFound,
+		},
+	}
+	h := NewApp(&Config{Silent: true}).Handler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			res := rec.Result()
+			if res.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := res.Header.Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cacheControl)
+			}
+			if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/javascript") {
+				t.Errorf("Content-Type = %q", got)
+			}
+			body, _ := io.ReadAll(res.Body)
+			if !bytes.Equal(body, clientJS) {
+				t.Error("body is not the embedded client")
+			}
+
+			// Revalidation with the ETag has no body
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("If-None-Match", res.Header.Get("ETag"))
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+				t.Errorf("revalidation status = %d with %d bytes, want %d", rec.Code, rec.Body.Len(), http.StatusNotModified)
+			}
+		})
+	}
+}
+
+func TestClientScript(t *testing.T) {
+	tests := []struct {
+		clientPath string
+		want       string
+	}{
+		{"", defaultClientPath + clientFile()},
+		{"/assets/fncmp", "/assets/fncmp/" + clientFile()},
+		{"/assets/fncmp/", "/assets/fncmp/" + clientFile()},
+	}
+	for _, tt := range tests {
+		app := NewApp(&Config{Silent: true, ClientPath: tt.clientPath})
+		html := RenderComponent(app.Script())
+		if !strings.Contains(html, `src="`+tt.want+`"`) {
+			t.Errorf("ClientPath %q: Script() = %s, want src %s", tt.clientPath, html, tt.want)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package fncmp
 
 import (
 	"context"
-	"fmt"
+	"html"
 	"io"
 
 	"github.com/google/uuid"
@@ -38,12 +38,13 @@ func NewFn(ctx context.Context, c Component) FnComponent {
 	dispatch := newDispatch(id)
 	dd, ok := ctx.Value(dispatchKey).(dispatchDetails)
 	if !ok {
-		config.Logger.Warn(ErrCtxMissingDispatch)
+		defaultApp.log.Warn(ErrCtxMissingDispatch.Error(), "key", id)
 	} else {
 		dispatch.conn = dd.Conn
 		dispatch.ConnID = dd.ConnID
 		dispatch.HandlerID = dd.HandlerID
 	}
+	dispatch.TraceID = TraceID(ctx)
 
 	f := FnComponent{
 		Context:  ctx,
@@ -57,18 +58,28 @@ func NewFn(ctx context.Context, c Component) FnComponent {
 }
 
 // Render renders the FnComponent with necessary metadata for the client
+//
+// Attribute values are escaped, so labels and event data cannot break out of the markup.
 func (f FnComponent) Render(ctx context.Context, w io.Writer) error {
-	if f.dispatch.Label == "" {
-		w.Write([]byte(fmt.Sprint("<div id='" + f.id + "' events=" + f.dispatch.FnRender.listenerStrings() + ">")))
-	} else {
-		w.Write([]byte(fmt.Sprint("<div id='" + f.id + "' label='" + f.dispatch.Label + "' events=" + f.dispatch.FnRender.listenerStrings() + ">")))
+	io.WriteString(w, "<div"+attr("id", f.id))
+	if f.dispatch.Label != "" {
+		io.WriteString(w, attr("label", f.dispatch.Label))
 	}
+	io.WriteString(w, attr("events", f.dispatch.FnRender.listenerStrings())+">")
 	HTML(f.dispatch.FnRender.HTML).Render(ctx, w)
 	w.Write(f.dispatch.buf)
 	w.Write([]byte("</div>"))
 	return nil
 }
 
+// traceContext returns the context spans of the FnComponent's dispatch are started from
+func (f FnComponent) traceContext() context.Context {
+	if f.Context == nil {
+		return context.Background()
+	}
+	return f.Context
+}
+
 // Write writes to the FnComponent's buffer
 func (f FnComponent) Write(p []byte) (n int, err error) {
 	f.dispatch.buf = append(f.dispatch.buf, p...)
@@ -81,12 +92,13 @@ func (f FnComponent) WithContext(ctx context.Context) FnComponent {
 
 	dd, ok := ctx.Value(dispatchKey).(dispatchDetails)
 	if !ok {
-		config.Logger.Warn(ErrCtxMissingDispatch)
+		f.dispatch.app().log.Warn(ErrCtxMissingDispatch.Error(), f.dispatch.logFields()...)
 		return f
 	}
 	f.dispatch.ConnID = dd.ConnID
 	f.dispatch.HandlerID = dd.HandlerID
 	f.dispatch.conn = dd.Conn
+	f.dispatch.TraceID = TraceID(ctx)
 	return f
 }
 
@@ -115,6 +127,8 @@ func (f FnComponent) WithError(err error) FnComponent {
 }
 
 // JS sets the FnComponent to run a custom JavaScript function
+//
+// The function must be registered on the client with fncmp.register, see JSFunc.
 func (f FnComponent) JS(fn string, arg any) FnComponent {
 	f.dispatch.Function = custom
 	f.dispatch.FnCustom.Function = fn
@@ -128,6 +142,14 @@ func (f FnComponent) JS(fn string, arg any) FnComponent {
 // especially during debugging.
 func (f FnComponent) WithLabel(label string) FnComponent {
 	f.dispatch.Label = label
+	// Listeners added before the label was set are labelled too
+	for i, el := range f.dispatch.FnRender.EventListeners {
+		el.Label = label
+		f.dispatch.FnRender.EventListeners[i] = el
+		if f.dispatch.conn != nil {
+			f.dispatch.app().listeners.Add(f.dispatch.conn, el)
+		}
+	}
 	return f
 }
 
@@ -225,16 +247,19 @@ func (f FnComponent) SwapElementInner(id string) FnComponent {
 
 // Dispatch immediately sends the FnComponent to the client
 func (f FnComponent) Dispatch() {
+	app := f.dispatch.app()
 	if f.dispatch.conn == nil {
-		config.Logger.Error(ErrConnectionNotFound)
+		app.log.Error(ErrConnectionNotFound.Error(), f.dispatch.logFields()...)
 		return
 	}
-	h, ok := handlers.Get(f.dispatch.HandlerID)
+	h, ok := app.handlers.Get(f.dispatch.HandlerID)
 	if !ok {
-		config.Logger.Error("handler not found", "HandlerID", f.dispatch.HandlerID)
+		app.log.Error("handler not found", f.dispatch.logFields()...)
 		return
 	}
-	h.out <- f
+	if !h.send(f) {
+		app.log.Warn(ErrShuttingDown.Error(), f.dispatch.logFields()...)
+	}
 }
 
 // RedirectURL redirects the client to the given url when returned from a handler
@@ -242,15 +267,27 @@ func RedirectURL(ctx context.Context, url string) FnComponent {
 	return NewFn(ctx, nil).WithRedirect(url)
 }
 
-// JS runs a custom JavaScript function on the client
+// JS runs a custom JavaScript function registered on the client with fncmp.register
 func JS(ctx context.Context, fn string, arg any) {
 	NewFn(ctx, nil).JS(fn, arg).Dispatch()
 }
 
 // HTML implements the Component interface for a string of HTML
+//
+// The string is rendered as is and must never contain user input, use Text instead.
 type HTML string
 
 func (h HTML) Render(ctx context.Context, w io.Writer) error {
 	_, err := w.Write([]byte(h))
 	return err
 }
+
+// Text implements the Component interface for a string of text
+//
+// The string is HTML escaped, so it is always rendered as text and never as markup.
+type Text string
+
+func (t Text) Render(ctx context.Context, w io.Writer) error {
+	_, err := io.WriteString(w, html.EscapeString(string(t)))
+	return err
+}
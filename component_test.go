@@ -0,0 +1,22 @@
+package fncmp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFnComponentEscapesAttributes(t *testing.T) {
+	ctx := context.WithValue(context.Background(), dispatchKey, dispatchDetails{})
+	label := `"><script>alert(1)</script>`
+	html := RenderComponent(NewFn(ctx, Text("<b>text</b>")).WithLabel(label))
+	if strings.Contains(html, "<script>") || strings.Contains(html, "<b>") {
+		t.Fatalf("unescaped markup in %s", html)
+	}
+	if !strings.Contains(html, `label="&#34;&gt;&lt;script&gt;`) {
+		t.Errorf("label not escaped in %s", html)
+	}
+	if !strings.Contains(html, "&lt;b&gt;text&lt;/b&gt;") {
+		t.Errorf("text not escaped in %s", html)
+	}
+}
@@ -1,19 +1,17 @@
 package fncmp
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-var connPool = conns{
-	pool: make(map[string]*conn),
-}
-
 func (c *conns) Get(id string) (*conn, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -33,61 +31,262 @@ func (c *conns) Delete(id string) {
 	delete(c.pool, id)
 }
 
+// Remove deletes conn unless another conn has since been set with its ID,
+// as when a client reconnects before its old conn is closed
+func (c *conns) Remove(conn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pool[conn.ID] == conn {
+		delete(c.pool, conn.ID)
+	}
+}
+
+func (c *conns) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pool)
+}
+
+// add sets conn unless the pool already holds max conns
+func (c *conns) add(conn *conn, max int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pool) >= max {
+		return false
+	}
+	c.pool[conn.ID] = conn
+	return true
+}
+
+func (c *conns) All() []*conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	all := make([]*conn, 0, len(c.pool))
+	for _, conn := range c.pool {
+		all = append(all, conn)
+	}
+	return all
+}
+
 type (
 	conns struct {
 		mu   sync.Mutex
 		pool map[string]*conn
 	}
 	conn struct {
-		websocket *websocket.Conn
+		app       *App
+		transport transport
 		ID        string
 		HandlerID string
-		Messages  chan []byte
+		encoding  Encoding
+		queue     *sendQueue
+		closeOnce sync.Once
+		// root is the HandleFn rendered once the client's handshake is
+		// accepted and again by Reload, request is the request that opened the conn
+		root    HandleFn
+		request *http.Request
+		// ctx is the parent of the contexts passed to HandleFns, it outlives
+		// the request and is cancelled when the conn closes
+		ctx    context.Context
+		cancel context.CancelFunc
+		// visible pauses timers while the client's page is hidden
+		visible pageVisibility
+		// hydrate is set when the server rendered the page, so the client
+		// attaches its listeners instead of receiving the first render
+		hydrate bool
+		// accepted is set once the client's handshake is accepted
+		accepted atomic.Bool
+		// calls are JSFunc calls waiting for a result from the client
+		calls pendingCalls
+		// jobs are the root renders and event handlers of the conn, run in
+		// order by its worker so they never block its reader or handler
+		jobs chan func()
+		// worked is closed once the worker has exited
+		worked chan struct{}
+		// closeCode and closeReason are sent to the client once the queue is drained
+		closeMu     sync.Mutex
+		closeCode   int
+		closeReason string
+		// done is closed once the conn has stopped writing to its transport
+		done chan struct{}
 	}
 )
 
-func newConn(w http.ResponseWriter, r *http.Request, handlerID string, ID string) (*conn, error) {
-	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			// TODO: check cookie for session id
-			// Set as conn id
-			return true
-			// host := strings.Split(r.Host, ":")[0]
-			// return host == "localhost"
-		},
-	}
-	websocket, err := upgrader.Upgrade(w, r, nil)
+// jobBuffer is how many events a conn queues while its worker is busy
+const jobBuffer = 64
+
+func (a *App) newConn(w http.ResponseWriter, r *http.Request, handlerID string, ID string, root HandleFn) (*conn, error) {
+	t, err := upgrade(w, r, a.config)
 	if err != nil {
-		return nil, errors.New("failed to upgrade connection")
+		return nil, err
+	}
+	// Claim the conn the page was rendered for by the server
+	c, ok := a.claim(ID, handlerID)
+	if ok {
+		a.attach(c, t, r)
+	} else {
+		c = a.addConn(t, r, handlerID, ID, root)
 	}
+	if sse, ok := t.(*sseTransport); ok {
+		sse.open()
+	}
+	return c, nil
+}
 
-	c := &conn{
-		websocket: websocket,
+// addConn creates a conn on an established transport
+func (a *App) addConn(t transport, r *http.Request, handlerID string, ID string, root HandleFn) *conn {
+	c := a.makeConn(r, handlerID, ID, root)
+	a.attach(c, t, r)
+	return c
+}
+
+// makeConn creates a conn that is not yet connected to its client
+func (a *App) makeConn(r *http.Request, handlerID string, ID string, root HandleFn) *conn {
+	// The request's context is cancelled once the connection is upgraded
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	queue := newSendQueue(a.config.SendBuffer, a.config.OverflowPolicy)
+	queue.onDrop = a.metrics.queueDropped
+	return &conn{
+		app:       a,
 		ID:        ID,
 		HandlerID: handlerID,
-		Messages:  make(chan []byte, 16),
+		encoding:  JSONEncoding,
+		root:      root,
+		request:   r,
+		ctx:       ctx,
+		cancel:    cancel,
+		queue:     queue,
+		jobs:      make(chan func(), jobBuffer),
+		worked:    make(chan struct{}),
+		done:      make(chan struct{}),
 	}
-	connPool.Set(c.ID, c)
-	return c, nil
+}
+
+// attach connects a conn to its client through t
+func (a *App) attach(c *conn, t transport, r *http.Request) {
+	c.transport = t
+	c.request = r
+	if a.config.MaxMessageSize > 0 {
+		t.SetReadLimit(a.config.MaxMessageSize)
+	}
+	a.conns.Set(c.ID, c)
+	a.observe(func(h Hooks) { h.ConnOpened(c.ID, c.HandlerID) })
+	go c.work()
+}
+
+// work runs the conn's jobs until it closes or its handler is stopped
+func (c *conn) work() {
+	defer close(c.worked)
+	h, ok := c.app.handlers.Get(c.HandlerID)
+	if !ok {
+		return
+	}
+	for {
+		select {
+		case job := <-c.jobs:
+			job()
+		case <-c.done:
+			return
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// run queues a job for the conn's worker, returning false if too many are queued
+func (c *conn) run(job func()) bool {
+	select {
+	case c.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// queueRoot queues a render of the conn's root HandleFn for its worker
+func (c *conn) queueRoot() {
+	if !c.run(c.renderRoot) {
+		c.app.log.Warn("too many jobs queued, dropping root render", c.logFields()...)
+		c.app.observe(func(h Hooks) { h.Error(errorKindOverflow) })
+	}
+}
+
+// renderRoot renders the conn's root HandleFn into the client's MainTag
+func (c *conn) renderRoot() {
+	h, ok := c.app.handlers.Get(c.HandlerID)
+	if !ok || c.root == nil {
+		return
+	}
+	h.reply(c.rootFn())
+}
+
+// rootFn calls the conn's root HandleFn
+func (c *conn) rootFn() FnComponent {
+	ctx := context.WithValue(c.ctx, dispatchKey, dispatchDetails{
+		ConnID:    c.ID,
+		Conn:      c,
+		HandlerID: c.HandlerID,
+	})
+	ctx = context.WithValue(ctx, RequestKey, c.request)
+
+	start := time.Now()
+	fn := c.root(ctx)
+	elapsed := time.Since(start)
+	c.app.observe(func(hooks Hooks) { hooks.Handled("handlefn", c.request.URL.Path, elapsed) })
+	fn.dispatch.conn = c
+	fn.dispatch.ConnID = c.ID
+	fn.dispatch.HandlerID = c.HandlerID
+	return fn
 }
 
 func (c *conn) close() error {
 	if c == nil {
 		return errors.New("cannot close nil connection")
 	}
-	evtListeners.Delete(c)
-	connPool.Delete(c.ID)
-	c.websocket.Close()
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.app.listeners.Delete(c)
+		c.app.conns.Remove(c)
+		c.queue.close()
+		c.transport.Close()
+		c.app.observe(func(h Hooks) { h.ConnClosed(c.ID, c.HandlerID) })
+	})
 	return nil
 }
 
+// drain closes the conn once its queued messages are written, sending the
+// client a close frame with the given code and reason
+func (c *conn) drain(code int, reason string) {
+	c.closeMu.Lock()
+	if c.closeReason == "" {
+		c.closeCode = code
+		c.closeReason = reason
+	}
+	c.closeMu.Unlock()
+	c.queue.close()
+}
+
 func (c *conn) listen() {
+	handshook := make(chan struct{})
+	go func() {
+		select {
+		case <-handshook:
+		case <-c.done:
+		case <-time.After(c.app.handshakeTimeout):
+			c.reject(ErrHandshakeTimeout)
+		}
+	}()
+
 	go func(c *conn) {
 		defer c.close()
 		// Listen for messages on conn's Messages channel
-		var dispatch Dispatch
+		handshaken := false
+		limits := newLimits(c.app.config.RateLimit)
 		for {
-			_, message, err := c.websocket.ReadMessage()
+			_, message, err := c.transport.ReadMessage()
+			if err == websocket.ErrReadLimit {
+				c.app.log.Warn("message exceeds MaxMessageSize, disconnecting client", c.logFields()...)
+			}
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(
 					err,
@@ -95,40 +294,103 @@ func (c *conn) listen() {
 					websocket.CloseAbnormalClosure,
 					websocket.CloseNormalClosure,
 				) {
-					log.Printf("error: %v", err)
+					c.app.log.Error("connection closed unexpectedly", c.logFields("error", err)...)
 				}
-				close(c.Messages)
+				c.queue.close()
 				break
 			}
+			// The first message must be the client's handshake
+			if !handshaken {
+				if err := c.handshake(message); err != nil {
+					c.reject(err)
+					// Let the writer flush the rejection before closing
+					<-c.done
+					return
+				}
+				close(handshook)
+				handshaken = true
+				c.accepted.Store(true)
+				if !c.hydrate {
+					c.queueRoot()
+				}
+				continue
+			}
+			if !c.limit(limits, limits.conn, "") {
+				continue
+			}
 			// Parse dispatch from websocket message
+			var dispatch Dispatch
 			err = json.Unmarshal(message, &dispatch)
 			if err != nil {
-				log.Printf("error: %v", err)
+				c.app.log.Error("invalid dispatch", c.logFields("error", err)...)
+				c.app.observe(func(h Hooks) { h.Error(errorKindDispatch) })
+				continue
+			}
+			// Set conn on dispatch
+			dispatch.conn = c
+			dispatch.received = time.Now()
+			c.app.inspect("in", &dispatch)
+			c.record("in", &dispatch)
+			// Clients choose the function name, so unknown ones are not reported as is
+			function := dispatch.Function
+			if !function.known() {
+				function = "unknown"
+			}
+			c.app.observe(func(h Hooks) { h.DispatchReceived(string(function)) })
+			// Results of JSFunc calls are resolved here, as the handler may be
+			// blocked waiting for them
+			if c.calls.resolve(dispatch) {
 				continue
 			}
+			if dispatch.Function == visibility {
+				c.visible.set(dispatch.FnVisibility.Hidden)
+				continue
+			}
+			if dispatch.Function == event {
+				if !c.verify(dispatch) {
+					c.app.log.Warn(ErrInvalidSignature.Error(), dispatch.logFields()...)
+					c.app.observe(func(h Hooks) { h.Error(errorKindSignature) })
+					continue
+				}
+				// Only existing listeners are tracked, so clients cannot grow the limiter map
+				if _, ok := c.app.listeners.Get(dispatch.FnEvent.ID, c); ok &&
+					!c.limit(limits, limits.listener(dispatch.FnEvent.ID), dispatch.FnEvent.ID) {
+					continue
+				}
+			}
 			// Get handler from handler pool
-			handler, ok := handlers.Get(dispatch.HandlerID)
+			handler, ok := c.app.handlers.Get(dispatch.HandlerID)
 			if !ok {
-				log.Printf("error: handler '%s' not found", dispatch.HandlerID)
+				c.app.log.Error("handler not found", dispatch.logFields()...)
 				continue
 			}
-			// Set conn on dispatch
-			dispatch.conn = c
-			// Dispatch to handler
-			handler.in <- dispatch
+			// Dispatch to handler, dropping events once it is stopped
+			select {
+			case handler.in <- dispatch:
+			case <-handler.done:
+			}
 		}
 	}(c)
 
+	defer close(c.done)
 	for {
-		msg, ok := <-c.Messages
+		o, ok := c.queue.pop()
 		if !ok {
+			c.closeMu.Lock()
+			code, reason := c.closeCode, c.closeReason
+			c.closeMu.Unlock()
+			if reason != "" {
+				c.writeClose(code, reason)
+			}
 			c.close()
 			break
 		}
 
-		if err := c.websocket.WriteMessage(1, msg); err != nil {
-			config.Logger.Error("error writing message", "error", err)
+		if err := c.transport.WriteMessage(o.msgType, o.msg); err != nil {
+			c.app.log.Error("error writing message", c.logFields("error", err)...)
+			c.app.observe(func(h Hooks) { h.Error(errorKindWrite) })
 			c.close()
+			break
 		}
 	}
 }
@@ -137,13 +399,63 @@ func (c *conn) Publish(msg []byte) {
 	// if msg is not json encodable, return
 	_, err := json.Marshal(msg)
 	if err != nil {
-		config.Logger.Error("error: message not json encodable", "error", err)
+		c.app.log.Error("message not json encodable", c.logFields("error", err)...)
 		return
 	}
-	c.Messages <- msg
+	c.enqueue("", websocket.TextMessage, msg)
+}
+
+// logFields returns the structured fields identifying a conn in logs
+func (c *conn) logFields(args ...any) []any {
+	return append([]any{"conn", c.ID, "handler", c.HandlerID}, args...)
+}
+
+// limit applies the App's LimitPolicy to a message, returning false if it must not be handled
+func (c *conn) limit(l *limits, lim *limiter, listenerID string) bool {
+	ok, wait := lim.allow()
+	if ok {
+		return true
+	}
+	c.app.log.Warn(ErrRateLimited.Error(),
+		c.logFields("listener", listenerID, "policy", l.config.Policy)...)
+	c.app.observe(func(h Hooks) { h.Error(errorKindRateLimit) })
+	switch l.config.Policy {
+	case LimitThrottle:
+		// Blocks only this conn's reader until the limit allows the message
+		for !ok {
+			time.Sleep(wait)
+			ok, wait = lim.allow()
+		}
+		return true
+	case LimitDisconnect:
+		c.drain(websocket.ClosePolicyViolation, ErrRateLimited.Error())
+		return false
+	default:
+		return false
+	}
+}
+
+// enqueue adds a message to the conn's outbound queue without blocking,
+// returning an error if it was not queued.
+//
+// key identifies the message's render target so the CoalesceTarget policy can
+// replace stale renders of the same element.
+func (c *conn) enqueue(key string, msgType int, msg []byte) error {
+	err := c.queue.push(outbound{key: key, msgType: msgType, msg: msg})
+	if err == nil {
+		return nil
+	}
+	if err == ErrQueueOverflow {
+		c.app.log.Warn("disconnecting slow client", c.logFields("error", err)...)
+		c.app.observe(func(h Hooks) { h.Error(errorKindOverflow) })
+		c.close()
+	}
+	return err
 }
 
 func (c *conn) Write(p []byte) (n int, err error) {
-	c.Messages <- p
+	if err := c.queue.push(outbound{msgType: websocket.TextMessage, msg: p}); err != nil {
+		return 0, err
+	}
 	return len(p), nil
 }
@@ -0,0 +1,172 @@
+package fncmp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testClient drives a conn over a memTransport the way the client runtime does
+type testClient struct {
+	t    *testing.T
+	conn *conn
+	tr   *memTransport
+	out  chan Dispatch
+}
+
+// newTestHandler returns a listening handler of app, stopped when the test ends
+func newTestHandler(t *testing.T, app *App) *handler {
+	h := app.newHandler()
+	h.listen()
+	t.Cleanup(h.stop)
+	return h
+}
+
+// newTestClient connects a client to h
+func newTestClient(t *testing.T, h *handler, root HandleFn) *testClient {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	tr := newMemTransport()
+	c := h.app.addConn(tr, r, h.id, uuid.New().String(), root)
+	return connectTestClient(t, c, tr)
+}
+
+// connectTestClient starts a conn attached to tr and sends its handshake
+func connectTestClient(t *testing.T, c *conn, tr *memTransport) *testClient {
+	go c.listen()
+	t.Cleanup(func() { c.close() })
+
+	tc := &testClient{t: t, conn: c, tr: tr, out: make(chan Dispatch, 64)}
+	go func() {
+		for {
+			select {
+			case msg := <-tr.out:
+				var d Dispatch
+				if err := json.Unmarshal(msg, &d); err != nil {
+					t.Errorf("invalid dispatch: %v", err)
+					continue
+				}
+				tc.out <- d
+			case <-tr.done:
+				return
+			}
+		}
+	}()
+
+	hello := newDispatch("fncmp-handshake")
+	hello.Function = handshake
+	hello.FnHandshake.Version = ProtocolVersion
+	tc.send(*hello)
+	if d := tc.next(); d.Function != handshake {
+		t.Fatalf("first dispatch = %s, want handshake", d.Function)
+	}
+	return tc
+}
+
+func (tc *testClient) send(d Dispatch) {
+	tc.t.Helper()
+	msg, err := json.Marshal(d)
+	if err != nil {
+		tc.t.Fatal(err)
+	}
+	select {
+	case tc.tr.in <- msg:
+	case <-time.After(5 * time.Second):
+		tc.t.Fatal("conn is not reading")
+	}
+}
+
+// next returns the next dispatch sent to the client
+func (tc *testClient) next() Dispatch {
+	tc.t.Helper()
+	select {
+	case d := <-tc.out:
+		return d
+	case <-time.After(5 * time.Second):
+		tc.t.Fatal("no dispatch received")
+		return Dispatch{}
+	}
+}
+
+// nextRender returns the next render sent to the client, skipping other dispatches
+func (tc *testClient) nextRender() Dispatch {
+	tc.t.Helper()
+	for {
+		if d := tc.next(); d.Function == render {
+			return d
+		}
+	}
+}
+
+// quiet fails the test if a dispatch is sent to the client within wait
+func (tc *testClient) quiet(wait time.Duration) {
+	tc.t.Helper()
+	select {
+	case d := <-tc.out:
+		tc.t.Fatalf("unexpected %s dispatch: %s", d.Function, d.FnRender.HTML)
+	case <-time.After(wait):
+	}
+}
+
+// fire sends a signed event for a listener, as when the client triggers it
+func (tc *testClient) fire(el EventListener) {
+	tc.t.Helper()
+	d := newDispatch("fncmp-event")
+	d.Function = event
+	d.ConnID = tc.conn.ID
+	d.HandlerID = tc.conn.HandlerID
+	d.FnEvent = el
+	d.FnEvent.Signature = tc.conn.app.sign(tc.conn.ID, tc.conn.HandlerID, el.ID)
+	tc.send(*d)
+}
+
+// listener returns the conn's only listener for an event
+func (tc *testClient) listener(on OnEvent) EventListener {
+	tc.t.Helper()
+	var found []EventListener
+	for _, el := range tc.conn.app.listeners.List(tc.conn) {
+		if el.On == on {
+			found = append(found, el)
+		}
+	}
+	if len(found) != 1 {
+		tc.t.Fatalf("found %d %s listeners, want 1", len(found), on)
+	}
+	return found[0]
+}
+
+func TestRootRender(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		return NewFn(ctx, HTML("<p>root</p>"))
+	})
+	d := tc.nextRender()
+	if d.FnRender.Tag != MainTag || !strings.Contains(d.FnRender.HTML, "<p>root</p>") {
+		t.Errorf("root render = %+v", d.FnRender)
+	}
+}
+
+func TestRootRenderOverflow(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	c := app.makeConn(httptest.NewRequest(http.MethodGet, "/", nil), "handler", "conn", nil)
+	// The conn has no worker, so its jobs are never taken
+	for i := 0; i < jobBuffer; i++ {
+		c.queueRoot()
+	}
+	var w strings.Builder
+	app.writeMetrics(&w)
+	if out := w.String(); strings.Contains(out, `kind="overflow"`) {
+		t.Fatalf("overflow reported before the jobs were full:\n%s", out)
+	}
+	c.queueRoot()
+	w.Reset()
+	app.writeMetrics(&w)
+	if out := w.String(); !strings.Contains(out, `fncmp_errors_total{kind="overflow"} 1`) {
+		t.Errorf("dropped root render not counted:\n%s", out)
+	}
+}
@@ -10,6 +10,10 @@ const (
 	RequestKey ContextKey = "request"
 	// ResponseKey is used to store http.ResponseWriter in context
 	ErrorKey ContextKey = "error"
+	// NonceKey is used to store the request's Content Security Policy nonce in context
+	NonceKey ContextKey = "nonce"
+	// TraceKey is used to store the trace ID of the event being handled in context
+	TraceKey ContextKey = "trace"
 	// dispatchKey is used internally to store dispatchDetails in context
 	dispatchKey ContextKey = "__dispatch__"
 )
@@ -0,0 +1,49 @@
+package fncmp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// StrictCSP is a strict Content Security Policy compatible with the client runtime.
+//
+// Use it as Config.CSP, "{nonce}" is replaced with the nonce of each request.
+const StrictCSP = "default-src 'self'; " +
+	"script-src 'nonce-{nonce}' 'strict-dynamic'; " +
+	"style-src 'self' 'nonce-{nonce}'; " +
+	"object-src 'none'; " +
+	"base-uri 'none'"
+
+// Nonce returns the CSP nonce of the request a context was created from,
+// or an empty string if Config.CSP is not set.
+//
+// Only the tags rendered by the library carry the nonce, templates add it to
+// their own script and style tags:
+//
+//	<script nonce="{{ .Nonce }}">...</script>
+func Nonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(NonceKey).(string)
+	return nonce
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("fncmp: failed to generate nonce: " + err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// withCSP sets the App's Content Security Policy on a page response and
+// stores a new nonce in the request's context
+func (a *App) withCSP(w http.ResponseWriter, r *http.Request) *http.Request {
+	if a.config.CSP == "" {
+		return r
+	}
+	nonce := newNonce()
+	w.Header().Set("Content-Security-Policy", strings.ReplaceAll(a.config.CSP, "{nonce}", nonce))
+	return r.WithContext(context.WithValue(r.Context(), NonceKey, nonce))
+}
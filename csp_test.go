@@ -0,0 +1,57 @@
+package fncmp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSPNonce(t *testing.T) {
+	app := NewApp(&Config{Silent: true, CSP: StrictCSP})
+	page := func(w http.ResponseWriter, r *http.Request) {
+		app.Script().Render(r.Context(), w)
+		// User content must not run under the page's nonce
+		io.WriteString(w, "<p><script>alert(1)</script><style>p{}</style></p>")
+	}
+	srv := httptest.NewServer(app.MiddleWareFn(page, func(ctx context.Context) FnComponent {
+		return NewFn(ctx, nil)
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	body := string(b)
+
+	policy := res.Header.Get("Content-Security-Policy")
+	start := strings.Index(policy, "'nonce-")
+	if start < 0 {
+		t.Fatalf("policy %q has no nonce", policy)
+	}
+	nonce := policy[start+len("'nonce-"):]
+	nonce = nonce[:strings.IndexByte(nonce, '\'')]
+
+	if !strings.Contains(body, `nonce="`+nonce+`" defer></script>`) {
+		t.Errorf("runtime script has no nonce: %s", body)
+	}
+	if strings.Count(body, "nonce=") != 1 {
+		t.Errorf("nonce added to user content: %s", body)
+	}
+}
+
+func TestNonceWithoutCSP(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	r := app.withCSP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if n := Nonce(r.Context()); n != "" {
+		t.Errorf("Nonce = %q without Config.CSP", n)
+	}
+	if s := RenderComponent(app.Script()); strings.Contains(s, "nonce") {
+		t.Errorf("script has a nonce without Config.CSP: %s", s)
+	}
+}
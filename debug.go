@@ -0,0 +1,321 @@
+package fncmp
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugHistory is the number of recent dispatches and errors kept for the inspector
+const debugHistory = 100
+
+// ring keeps the last n values added to it
+type ring[T any] struct {
+	mu     sync.Mutex
+	values []T
+	next   int
+	full   bool
+}
+
+func newRing[T any](n int) *ring[T] {
+	return &ring[T]{values: make([]T, n)}
+}
+
+func (r *ring[T]) add(v T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[r.next] = v
+	r.next = (r.next + 1) % len(r.values)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// recent returns the values newest first
+func (r *ring[T]) recent() []T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.values)
+	}
+	out := make([]T, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, r.values[(r.next-i+len(r.values))%len(r.values)])
+	}
+	return out
+}
+
+// debugEntry is a dispatch or error shown by the inspector
+type debugEntry struct {
+	Time      time.Time
+	Direction string
+	ConnID    string
+	Function  functionName
+	Label     string
+	TraceID   string
+	Detail    string
+}
+
+// inspector records the App's recent dispatches and errors while in DevMode
+type inspector struct {
+	dispatches *ring[debugEntry]
+	errors     *ring[debugEntry]
+	// token is embedded in the inspector page and required by its actions,
+	// so other pages open in the browser cannot post to them
+	token string
+}
+
+func newInspector() *inspector {
+	return &inspector{
+		dispatches: newRing[debugEntry](debugHistory),
+		errors:     newRing[debugEntry](debugHistory),
+		token:      newTraceID(),
+	}
+}
+
+// inspect records a dispatch read from ("in") or sent to ("out") a client
+func (a *App) inspect(direction string, d *Dispatch) {
+	if !a.config.DevMode {
+		return
+	}
+	entry := d.debugEntry(direction)
+	if d.Function == _error {
+		a.inspector.errors.add(entry)
+		return
+	}
+	a.inspector.dispatches.add(entry)
+}
+
+// inspectError records a dispatch that failed on the server
+func (a *App) inspectError(d *Dispatch) {
+	if !a.config.DevMode {
+		return
+	}
+	a.inspector.errors.add(d.debugEntry("server"))
+}
+
+func (d *Dispatch) debugEntry(direction string) debugEntry {
+	entry := debugEntry{
+		Time:      time.Now(),
+		Direction: direction,
+		ConnID:    d.ConnID,
+		Function:  d.Function,
+		Label:     d.Label,
+		TraceID:   d.TraceID,
+	}
+	if d.conn != nil {
+		entry.ConnID = d.conn.ID
+	}
+	switch d.Function {
+	case event:
+		entry.Detail = string(d.FnEvent.On) + " " + d.FnEvent.ID
+	case render:
+		target := "#" + d.FnRender.TargetID
+		if d.FnRender.Tag != "" {
+			target = "<" + string(d.FnRender.Tag) + ">"
+		}
+		entry.Detail = fmt.Sprintf("%s, %d bytes", target, len(d.FnRender.HTML))
+	case redirect:
+		entry.Detail = d.FnRedirect.URL
+	case custom:
+		entry.Detail = d.FnCustom.Function
+	case _error:
+		entry.Detail = d.FnError.Message
+	}
+	return entry
+}
+
+// debugPath returns the inspector route of a request to the App's Handler
+func (a *App) debugPath(p string) (string, bool) {
+	p = strings.TrimPrefix(p, a.clientPath())
+	if p != "debug" && !strings.HasPrefix(p, "debug/") {
+		return "", false
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p, "debug"), "/"), true
+}
+
+// serveDebug serves the inspector page and its actions
+func (a *App) serveDebug(w http.ResponseWriter, r *http.Request, route string) {
+	switch {
+	case route == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := debugPage.Execute(w, a.debugState()); err != nil {
+			a.log.Error("error rendering debug page", "error", err)
+		}
+		return
+	case r.Method != http.MethodPost:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	case subtle.ConstantTimeCompare([]byte(r.FormValue("token")), []byte(a.inspector.token)) != 1:
+		http.Error(w, ErrInvalidDebugToken.Error(), http.StatusForbidden)
+		return
+	}
+
+	c, ok := a.conns.Get(r.FormValue("conn"))
+	if !ok {
+		http.Error(w, ErrConnectionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	var err error
+	switch route {
+	case "fire":
+		err = c.fire(r.FormValue("listener"), r.FormValue("data"))
+	case "render":
+		c.pushRender("fncmp-debug", Tag(r.FormValue("tag")), r.FormValue("target_id"), r.FormValue("html"))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, a.clientPath()+"debug/", http.StatusSeeOther)
+}
+
+// fire dispatches an event to a conn's listener as if the client had sent it
+func (c *conn) fire(listenerID string, data string) error {
+	listener, ok := c.app.listeners.Get(listenerID, c)
+	if !ok {
+		return fmt.Errorf("event listener with id '%s' not found", listenerID)
+	}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &listener.Data); err != nil {
+			return fmt.Errorf("invalid event data: %w", err)
+		}
+	}
+	h, ok := c.app.handlers.Get(c.HandlerID)
+	if !ok {
+		return fmt.Errorf("handler '%s' not found", c.HandlerID)
+	}
+	d := newDispatch("fncmp-debug")
+	d.conn = c
+	d.ConnID = c.ID
+	d.HandlerID = c.HandlerID
+	d.Function = event
+	d.Label = listener.Label
+	d.FnEvent = listener
+	c.app.inspect("debug", d)
+	select {
+	case h.in <- *d:
+		return nil
+	case <-h.done:
+		return ErrShuttingDown
+	}
+}
+
+type debugConn struct {
+	ID        string
+	HandlerID string
+	Encoding  Encoding
+	Queue     QueueStats
+	Listeners []EventListener
+}
+
+type debugState struct {
+	Path       string
+	Token      string
+	Conns      []debugConn
+	Dispatches []debugEntry
+	Errors     []debugEntry
+}
+
+func (a *App) debugState() debugState {
+	state := debugState{
+		Path:       a.clientPath() + "debug/",
+		Token:      a.inspector.token,
+		Dispatches: a.inspector.dispatches.recent(),
+		Errors:     a.inspector.errors.recent(),
+	}
+	queues := make(map[string]QueueStats)
+	for _, q := range a.Queues() {
+		queues[q.ConnID] = q
+	}
+	for _, c := range a.conns.All() {
+		listeners := a.listeners.List(c)
+		sort.Slice(listeners, func(i, j int) bool {
+			if listeners[i].Label != listeners[j].Label {
+				return listeners[i].Label < listeners[j].Label
+			}
+			return listeners[i].ID < listeners[j].ID
+		})
+		state.Conns = append(state.Conns, debugConn{
+			ID:        c.ID,
+			HandlerID: c.HandlerID,
+			Encoding:  c.encoding,
+			Queue:     queues[c.ID],
+			Listeners: listeners,
+		})
+	}
+	sort.Slice(state.Conns, func(i, j int) bool { return state.Conns[i].ID < state.Conns[j].ID })
+	return state
+}
+
+var debugPage = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>fncmp inspector</title>
+</head>
+<body>
+<h1>fncmp inspector</h1>
+<p><a href="{{.Path}}">Refresh</a></p>
+
+<h2>Connections ({{len .Conns}})</h2>
+{{range .Conns}}
+<section>
+<h3>{{.ID}}</h3>
+<p>handler {{.HandlerID}}, {{.Encoding}} encoding, queue {{.Queue.Depth}}/{{.Queue.Capacity}} ({{.Queue.Policy}}), {{.Queue.Dropped}} dropped, {{.Queue.Coalesced}} coalesced</p>
+<table>
+<tr><th>Label</th><th>Event</th><th>Target</th><th>Listener</th><th>Fire with JSON data</th></tr>
+{{$conn := .ID}}
+{{range .Listeners}}
+<tr>
+<td>{{.Label}}</td><td>{{.On}}</td><td>{{.TargetID}}</td><td>{{.ID}}</td>
+<td><form method="post" action="{{$.Path}}fire">
+<input type="hidden" name="token" value="{{$.Token}}">
+<input type="hidden" name="conn" value="{{$conn}}">
+<input type="hidden" name="listener" value="{{.ID}}">
+<input name="data" placeholder="{}">
+<button>Fire</button>
+</form></td>
+</tr>
+{{end}}
+</table>
+<form method="post" action="{{$.Path}}render">
+<input type="hidden" name="token" value="{{$.Token}}">
+<input type="hidden" name="conn" value="{{.ID}}">
+<input name="tag" value="main" placeholder="tag">
+<input name="target_id" placeholder="or target id">
+<textarea name="html" placeholder="HTML"></textarea>
+<button>Push render</button>
+</form>
+</section>
+{{end}}
+
+<h2>Recent dispatches</h2>
+<table>
+<tr><th>Time</th><th>Direction</th><th>Connection</th><th>Function</th><th>Label</th><th>Detail</th><th>Trace</th></tr>
+{{range .Dispatches}}
+<tr><td>{{.Time.Format "15:04:05.000"}}</td><td>{{.Direction}}</td><td>{{.ConnID}}</td><td>{{.Function}}</td><td>{{.Label}}</td><td>{{.Detail}}</td><td>{{.TraceID}}</td></tr>
+{{end}}
+</table>
+
+<h2>Recent errors</h2>
+<table>
+<tr><th>Time</th><th>Source</th><th>Connection</th><th>Label</th><th>Message</th></tr>
+{{range .Errors}}
+<tr><td>{{.Time.Format "15:04:05.000"}}</td><td>{{.Direction}}</td><td>{{.ConnID}}</td><td>{{.Label}}</td><td>{{.Detail}}</td></tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
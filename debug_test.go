@@ -0,0 +1,93 @@
+package fncmp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postDebug posts an inspector action to the App's Handler
+func postDebug(app *App, action string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, defaultClientPath+"debug/"+action, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDebugRequiresDevMode(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	rec := httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, defaultClientPath+"debug/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("inspector status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	form := url.Values{"token": {app.inspector.token}}
+	if rec := postDebug(app, "render", form); rec.Code != http.StatusNotFound {
+		t.Errorf("render status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestDebugToken(t *testing.T) {
+	app := NewApp(&Config{Silent: true, DevMode: true})
+	tc := newTestClient(t, newTestHandler(t, app), clickCounter)
+	tc.nextRender()
+
+	rec := httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, defaultClientPath+"debug/", nil))
+	if !strings.Contains(rec.Body.String(), `name="token" value="`+app.inspector.token+`"`) {
+		t.Fatal("inspector page does not embed the token")
+	}
+
+	for _, token := range []string{"", "wrong", strings.Repeat("0", len(app.inspector.token))} {
+		form := url.Values{"conn": {tc.conn.ID}, "html": {"forged"}, "tag": {"main"}, "token": {token}}
+		if rec := postDebug(app, "render", form); rec.Code != http.StatusForbidden {
+			t.Errorf("token %q: status = %d, want %d", token, rec.Code, http.StatusForbidden)
+		}
+	}
+	tc.quiet(100 * time.Millisecond)
+}
+
+func TestDebugActions(t *testing.T) {
+	app := NewApp(&Config{Silent: true, DevMode: true})
+	tc := newTestClient(t, newTestHandler(t, app), clickCounter)
+	tc.nextRender()
+	token := app.inspector.token
+
+	rec := postDebug(app, "fire", url.Values{
+		"conn":     {tc.conn.ID},
+		"listener": {tc.listener(OnClick).ID},
+		"token":    {token},
+	})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("fire status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "clicked") {
+		t.Errorf("fired render = %q, want clicked", d.FnRender.HTML)
+	}
+
+	rec = postDebug(app, "render", url.Values{
+		"conn":  {tc.conn.ID},
+		"tag":   {"main"},
+		"html":  {"<p>pushed</p>"},
+		"token": {token},
+	})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("render status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if d := tc.nextRender(); d.FnRender.Tag != MainTag || !strings.Contains(d.FnRender.HTML, "pushed") {
+		t.Errorf("pushed render = %+v", d.FnRender)
+	}
+
+	rec = postDebug(app, "fire", url.Values{"conn": {tc.conn.ID}, "listener": {"missing"}, "token": {token}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing listener status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = postDebug(app, "fire", url.Values{"conn": {"missing"}, "token": {token}})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing conn status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
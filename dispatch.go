@@ -1,6 +1,9 @@
 package fncmp
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type functionName string
 
@@ -10,8 +13,22 @@ const (
 	event    functionName = "event"
 	custom   functionName = "custom"
 	_error   functionName = "error"
+	// handshake is exchanged once when a connection opens
+	handshake functionName = "handshake"
+	// keyed changes a single item of a KeyedList
+	keyed functionName = "keyed"
+	// visibility is sent by the client when its page is hidden or shown
+	visibility functionName = "visibility"
 )
 
+func (f functionName) known() bool {
+	switch f {
+	case render, redirect, event, custom, _error, handshake, keyed, visibility:
+		return true
+	}
+	return false
+}
+
 type Tag string
 
 const (
@@ -31,6 +48,8 @@ type (
 		Prepend        bool            `json:"prepend"`
 		HTML           string          `json:"html"`
 		EventListeners []EventListener `json:"event_listeners"`
+		// Await makes the client hold the render until its target exists
+		Await bool `json:"await,omitempty"`
 	}
 	FnRedirect struct {
 		URL string `json:"url"`
@@ -38,10 +57,23 @@ type (
 	FnCustom struct {
 		Function string `json:"function"`
 		Data     any    `json:"data"`
+		// Result is the value returned by the client function of a JSFunc call
+		Result json.RawMessage `json:"result,omitempty"`
 	}
 	FnError struct {
 		Message string `json:"message"`
 	}
+	// FnKeyed is an operation on the item with Key in the KeyedList with
+	// the FnRender's TargetID, inserted or updated items are in its HTML
+	FnKeyed struct {
+		Op    keyedOp `json:"op"`
+		Key   string  `json:"key"`
+		Index int     `json:"index"`
+	}
+	// FnVisibility reports whether the client's page is hidden
+	FnVisibility struct {
+		Hidden bool `json:"hidden"`
+	}
 )
 
 func newDispatch(key string) *Dispatch {
@@ -68,6 +100,15 @@ type Dispatch struct {
 	FnRedirect FnRedirect    `json:"redirect"`
 	FnCustom   FnCustom      `json:"custom"`
 	FnError    FnError       `json:"error"`
+	FnKeyed    FnKeyed       `json:"keyed"`
+	// FnVisibility is only set on visibility dispatches
+	FnVisibility FnVisibility `json:"visibility"`
+	// FnHandshake is only set on handshake dispatches
+	FnHandshake FnHandshake `json:"handshake"`
+	// TraceID correlates an event with the dispatches sent in response
+	TraceID string `json:"trace_id,omitempty"`
+	// received is when an inbound dispatch was read from the client
+	received time.Time `json:"-"`
 }
 
 func (f *FnRender) listenerStrings() string {
@@ -77,3 +118,16 @@ func (f *FnRender) listenerStrings() string {
 	}
 	return string(b)
 }
+
+// coalesceKey identifies the DOM target replaced by a render dispatch.
+//
+// Appends and prepends are not idempotent, so only swaps can be coalesced.
+func (d *Dispatch) coalesceKey() string {
+	if d.Function != render || !(d.FnRender.Inner || d.FnRender.Outer) {
+		return ""
+	}
+	if d.FnRender.Tag != "" {
+		return "tag:" + string(d.FnRender.Tag)
+	}
+	return "id:" + d.FnRender.TargetID
+}
@@ -0,0 +1,42 @@
+package fncmp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+)
+
+// Encoding is the wire format used for dispatches sent to a client
+type Encoding string
+
+const (
+	// JSONEncoding sends each dispatch as a JSON text frame
+	JSONEncoding Encoding = "json"
+	// BinaryEncoding sends each dispatch as a length-prefixed binary frame
+	//
+	// The frame is a 4 byte big-endian header length, the JSON encoded dispatch
+	// without its rendered HTML, and the raw HTML bytes. This avoids escaping
+	// the markup as a JSON string, which dominates the size of large renders.
+	BinaryEncoding Encoding = "binary"
+)
+
+// marshal encodes a dispatch and returns it with its websocket message type
+func (e Encoding) marshal(d Dispatch) (int, []byte, error) {
+	if e != BinaryEncoding {
+		b, err := json.Marshal(d)
+		return websocket.TextMessage, b, err
+	}
+
+	html := d.FnRender.HTML
+	d.FnRender.HTML = ""
+	header, err := json.Marshal(d)
+	if err != nil {
+		return 0, nil, err
+	}
+	frame := make([]byte, 4, 4+len(header)+len(html))
+	binary.BigEndian.PutUint32(frame, uint32(len(header)))
+	frame = append(frame, header...)
+	frame = append(frame, html...)
+	return websocket.BinaryMessage, frame, nil
+}
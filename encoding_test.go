@@ -0,0 +1,121 @@
+package fncmp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// unmarshal decodes a message encoded by Encoding.marshal, as the client does
+func unmarshal(t *testing.T, msgType int, msg []byte) Dispatch {
+	t.Helper()
+	var d Dispatch
+	if msgType == websocket.TextMessage {
+		if err := json.Unmarshal(msg, &d); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	if len(msg) < 4 {
+		t.Fatalf("binary frame of %d bytes", len(msg))
+	}
+	n := binary.BigEndian.Uint32(msg)
+	if err := json.Unmarshal(msg[4:4+n], &d); err != nil {
+		t.Fatal(err)
+	}
+	d.FnRender.HTML = string(msg[4+n:])
+	return d
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	dispatches := []struct {
+		name     string
+		dispatch Dispatch
+	}{
+		{
+			name: "render",
+			dispatch: Dispatch{ID: "1", Function: render, FnRender: FnRender{
+				TargetID: "target",
+				Inner:    true,
+				HTML:     `<p class="a">"quoted" & <b>ünïcode</b></p>`,
+			}},
+		},
+		{
+			name:     "empty render",
+			dispatch: Dispatch{ID: "2", Function: render, FnRender: FnRender{Tag: MainTag}},
+		},
+		{
+			name:     "redirect",
+			dispatch: Dispatch{ID: "3", Function: redirect, FnRedirect: FnRedirect{URL: "/home"}},
+		},
+	}
+	encodings := []struct {
+		encoding Encoding
+		msgType  int
+	}{
+		{JSONEncoding, websocket.TextMessage},
+		{BinaryEncoding, websocket.BinaryMessage},
+		{"", websocket.TextMessage},
+	}
+	for _, e := range encodings {
+		for _, tt := range dispatches {
+			t.Run(string(e.encoding)+"/"+tt.name, func(t *testing.T) {
+				msgType, msg, err := e.encoding.marshal(tt.dispatch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if msgType != e.msgType {
+					t.Fatalf("message type = %d, want %d", msgType, e.msgType)
+				}
+				got := unmarshal(t, msgType, msg)
+				want := tt.dispatch
+				if got.ID != want.ID || got.Function != want.Function ||
+					got.FnRender.TargetID != want.FnRender.TargetID ||
+					got.FnRender.Tag != want.FnRender.Tag ||
+					got.FnRender.Inner != want.FnRender.Inner ||
+					got.FnRender.HTML != want.FnRender.HTML ||
+					got.FnRedirect != want.FnRedirect {
+					t.Errorf("decoded %+v, want %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestEncodingNegotiation(t *testing.T) {
+	tests := []struct {
+		name         string
+		transport    transport
+		capabilities []string
+		want         Encoding
+	}{
+		{name: "no capabilities", transport: newMemTransport(), want: JSONEncoding},
+		{name: "other capabilities", transport: newMemTransport(), capabilities: []string{"other"}, want: JSONEncoding},
+		{name: "binary", transport: newMemTransport(), capabilities: []string{FeatureBinary}, want: BinaryEncoding},
+		{name: "binary over sse", transport: &sseTransport{}, capabilities: []string{FeatureBinary}, want: JSONEncoding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp(&Config{Silent: true})
+			c := app.makeConn(httptest.NewRequest(http.MethodGet, "/", nil), "handler", "conn", nil)
+			c.transport = tt.transport
+			hello := newDispatch("fncmp-handshake")
+			hello.Function = handshake
+			hello.FnHandshake = FnHandshake{Version: ProtocolVersion, Capabilities: tt.capabilities}
+			msg, err := json.Marshal(hello)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.handshake(msg); err != nil {
+				t.Fatal(err)
+			}
+			if c.encoding != tt.want {
+				t.Errorf("encoding = %s, want %s", c.encoding, tt.want)
+			}
+		})
+	}
+}
@@ -11,4 +11,16 @@ const (
 	ErrNoClientConnection DispatchError = "no connection to client"
 	ErrConnectionNotFound DispatchError = "connection not found"
 	ErrConnectionFailed   DispatchError = "connection failed"
+	ErrConnectionClosed   DispatchError = "connection closed"
+	ErrQueueOverflow      DispatchError = "outbound queue overflow"
+	ErrShuttingDown       DispatchError = "server shutting down"
+	ErrHandshakeRequired  DispatchError = "handshake required"
+	ErrHandshakeTimeout   DispatchError = "handshake timed out"
+	ErrProtocolVersion    DispatchError = "unsupported protocol version"
+	ErrRateLimited        DispatchError = "rate limit exceeded"
+	ErrInvalidSignature   DispatchError = "invalid event signature"
+	ErrDevModeRequired    DispatchError = "dev mode required"
+	ErrInvalidDebugToken  DispatchError = "invalid inspector token"
+	ErrSuspenseTimeout    DispatchError = "suspended component timed out"
+	ErrInvalidConfig      DispatchError = "invalid config value, using default"
 )
@@ -95,6 +95,12 @@ const (
 	OnWheel              OnEvent = "wheel"
 )
 
+// Synthetic event types dispatched by the client runtime
+const (
+	// OnVisible is dispatched once when the element enters the viewport
+	OnVisible OnEvent = "visible"
+)
+
 type EventListener struct {
 	context.Context `json:"-"`
 	ID              string   `json:"id"`
@@ -102,53 +108,69 @@ type EventListener struct {
 	Handler         HandleFn `json:"-"`
 	On              OnEvent  `json:"on"`
 	Data            any      `json:"data"`
+	// Signature proves the listener was rendered by the server for the client's connection
+	Signature string `json:"signature"`
+	// Label is the label of the FnComponent the listener was added to
+	Label string `json:"label,omitempty"`
 }
 
 func newEventListener(on OnEvent, f FnComponent, h HandleFn) EventListener {
-	if f.dispatch.conn == nil {
-		config.Logger.Error("connection not found")
-	}
 	id := uuid.New().String()
 	el := EventListener{
-		Context:  f.Context,
-		ID:       id,
-		TargetID: f.id,
-		Handler:  h,
-		On:       on,
+		Context:   f.Context,
+		ID:        id,
+		TargetID:  f.id,
+		Handler:   h,
+		On:        on,
+		Label:     f.dispatch.Label,
+		Signature: f.dispatch.app().sign(f.dispatch.ConnID, f.dispatch.HandlerID, id),
+	}
+	// Listeners rendered without a connection can never be triggered
+	if f.dispatch.conn == nil {
+		f.dispatch.app().log.Error(ErrConnectionNotFound.Error(), f.dispatch.logFields("on", on)...)
+		return el
 	}
-	evtListeners.Add(f.dispatch.conn, el)
+	f.dispatch.app().listeners.Add(f.dispatch.conn, el)
 	return el
 }
 
-// Store and retrieve event listeners
+// Store and retrieve event listeners, by conn rather than by the client
+// chosen conn ID, which a reconnecting client reuses
 type eventListeners struct {
 	mu sync.Mutex
-	el map[string]map[string]EventListener
-}
-
-var evtListeners = eventListeners{
-	el: make(map[string]map[string]EventListener),
+	el map[*conn]map[string]EventListener
 }
 
 func (e *eventListeners) Add(conn *conn, el EventListener) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	if _, ok := e.el[conn.ID]; !ok {
-		e.el[conn.ID] = make(map[string]EventListener)
+	if _, ok := e.el[conn]; !ok {
+		e.el[conn] = make(map[string]EventListener)
 	}
-	e.el[conn.ID][el.ID] = el
+	e.el[conn][el.ID] = el
 }
 
 func (e *eventListeners) Delete(conn *conn) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	delete(e.el, conn.ID)
+	delete(e.el, conn)
+}
+
+// List returns the event listeners of a conn
+func (e *eventListeners) List(conn *conn) []EventListener {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := make([]EventListener, 0, len(e.el[conn]))
+	for _, el := range e.el[conn] {
+		list = append(list, el)
+	}
+	return list
 }
 
 func (e *eventListeners) Get(id string, conn *conn) (EventListener, bool) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	event, ok := e.el[conn.ID][id]
+	event, ok := e.el[conn][id]
 	return event, ok
 }
 
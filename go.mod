@@ -2,27 +2,9 @@ module github.com/kitkitchen/fncmp
 
 go 1.21.5
 
-require (
-	github.com/charmbracelet/log v0.3.1
-	github.com/gorilla/websocket v1.5.1
-)
-
-require (
-	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
-	github.com/charmbracelet/lipgloss v0.9.1 // indirect
-	github.com/go-logfmt/logfmt v0.6.0 // indirect
-	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/mattn/go-runewidth v0.0.15 // indirect
-	github.com/muesli/reflow v0.3.0 // indirect
-	github.com/muesli/termenv v0.15.2 // indirect
-	github.com/rivo/uniseg v0.2.0 // indirect
-	golang.org/x/exp v0.0.0-20231006140011-7918f672742d // indirect
-	golang.org/x/sys v0.15.0 // indirect
-)
+require github.com/gorilla/websocket v1.5.1
 
 require (
-	github.com/a-h/templ v0.2.513
 	github.com/google/uuid v1.6.0
 	golang.org/x/net v0.19.0 // indirect
 )
@@ -2,18 +2,14 @@ package fncmp
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-var handlers = handlerPool{
-	pool: make(map[string]handler),
-}
-
 type handlerPool struct {
 	mu   sync.Mutex
 	pool map[string]handler
@@ -38,24 +34,40 @@ func (h *handlerPool) Delete(id string) {
 	delete(h.pool, id)
 }
 
+func (h *handlerPool) All() []handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	all := make([]handler, 0, len(h.pool))
+	for _, handler := range h.pool {
+		all = append(all, handler)
+	}
+	return all
+}
+
 type HandleFn func(context.Context) FnComponent
 
 type handler struct {
 	http.Handler
+	app       *App
 	id        string
 	in        chan Dispatch
 	out       chan FnComponent
+	done      chan struct{}
+	stopOnce  *sync.Once
 	handlesFn map[string]HandleFn
 }
 
-func newHandler() *handler {
+func (a *App) newHandler() *handler {
 	handler := handler{
+		app:       a,
 		id:        uuid.New().String(),
 		in:        make(chan Dispatch, 1028),
 		out:       make(chan FnComponent, 1028),
+		done:      make(chan struct{}),
+		stopOnce:  &sync.Once{},
 		handlesFn: make(map[string]HandleFn),
 	}
-	handlers.Set(handler.id, handler)
+	a.handlers.Set(handler.id, handler)
 	return &handler
 }
 
@@ -64,51 +76,126 @@ func (h handler) ID() string {
 }
 
 func (h *handler) listen() {
+	h.app.workers.Add(2)
 	go func(h *handler) {
-		for d := range h.in {
-			switch d.Function {
-			case event:
-				h.Event(d)
-			case _error:
-				h.Error(d)
-			default:
-				d.FnError.Message = fmt.Sprintf(
-					"function '%s' found, expected event or error on 'in' channel", d.Function)
-				h.Error(d)
+		defer h.app.workers.Done()
+		for {
+			select {
+			case d := <-h.in:
+				// Events are handled by their conn's worker, so a slow
+				// listener only delays its own client
+				if d.Function == event && d.conn != nil {
+					if !d.conn.run(func() { h.receive(d) }) {
+						h.app.log.Warn("too many events queued, dropping event", d.logFields()...)
+						h.app.observe(func(hooks Hooks) { hooks.Error(errorKindOverflow) })
+					}
+					continue
+				}
+				h.receive(d)
+			case <-h.done:
 				return
 			}
 		}
 	}(h)
 	go func(h *handler) {
-		for fn := range h.out {
-			switch fn.dispatch.Function {
-			case render:
-				h.Render(fn)
-			case redirect:
-				h.Redirect(fn)
-			case custom:
-				h.Custom(fn)
-			case _error:
-				h.Error(*fn.dispatch)
-			default:
-				fn.dispatch.FnError.Message = fmt.Sprintf(
-					"function '%s' found, expected event or error on 'in' channel", fn.dispatch.Function)
-				h.Error(*fn.dispatch)
-				return
+		defer h.app.workers.Done()
+		for {
+			select {
+			case fn := <-h.out:
+				h.dispatch(fn)
+			case <-h.done:
+				// Flush components queued before the handler was stopped
+				for {
+					select {
+					case fn := <-h.out:
+						h.dispatch(fn)
+					default:
+						return
+					}
+				}
 			}
 		}
 	}(h)
 }
 
+// stop signals the handler's goroutines to exit once queued components are flushed
+func (h handler) stop() {
+	h.stopOnce.Do(func() {
+		close(h.done)
+	})
+}
+
+// receive processes a dispatch from the 'in' channel
+func (h handler) receive(d Dispatch) {
+	switch d.Function {
+	case event:
+		h.Event(d)
+	case _error:
+		h.Error(d)
+	default:
+		d.FnError.Message = fmt.Sprintf(
+			"function '%s' found, expected event or error on 'in' channel", d.Function)
+		h.Error(d)
+	}
+}
+
+// dispatch processes a component from the 'out' channel
+func (h handler) dispatch(fn FnComponent) {
+	switch fn.dispatch.Function {
+	case render:
+		h.Render(fn)
+	case redirect:
+		h.Redirect(fn)
+	case custom:
+		h.Custom(fn)
+	case keyed:
+		h.Keyed(fn)
+	case _error:
+		h.Error(*fn.dispatch)
+	default:
+		fn.dispatch.FnError.Message = fmt.Sprintf(
+			"function '%s' found, expected render, redirect, custom, keyed or error on 'out' channel", fn.dispatch.Function)
+		h.Error(*fn.dispatch)
+	}
+}
+
+// send queues a component for dispatch, returning false if the handler is stopped
+func (h handler) send(fn FnComponent) bool {
+	select {
+	case <-h.done:
+		return false
+	default:
+	}
+	select {
+	case h.out <- fn:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// reply sends the result of a job run by a conn's worker. Once the handler is
+// stopped it is dispatched directly, as Shutdown lets workers finish the job
+// in flight before draining their conn.
+func (h handler) reply(fn FnComponent) {
+	if !h.send(fn) {
+		h.dispatch(fn)
+	}
+}
+
 func (h handler) Render(fn FnComponent) {
 	// If there is no HTML to render, cancel dispatch
 	if len(fn.dispatch.buf) == 0 && fn.dispatch.FnRender.HTML == "" {
 		return
 	}
+	ctx, span := h.app.tracer().Start(fn.traceContext(), "fncmp.render",
+		fn.dispatch.logFields("trace_id", fn.dispatch.TraceID)...)
 	var data Writer
 	fn.Render(context.Background(), &data)
-	fn.dispatch.FnRender.HTML = sanitizeHTML(string(data.buf))
-	h.MarshalAndPublish(*fn.dispatch)
+	fn.dispatch.FnRender.HTML = h.app.minify(string(data.buf))
+	span.SetAttributes("bytes", len(fn.dispatch.FnRender.HTML))
+	span.End()
+	h.MarshalAndPublish(ctx, *fn.dispatch)
 }
 
 func (h handler) Redirect(fn FnComponent) {
@@ -116,29 +203,49 @@ func (h handler) Redirect(fn FnComponent) {
 	if fn.dispatch.FnRedirect.URL == "" {
 		return
 	}
-	h.MarshalAndPublish(*fn.dispatch)
+	h.MarshalAndPublish(fn.traceContext(), *fn.dispatch)
 }
 
 func (h handler) Custom(fn FnComponent) {
 	if fn.dispatch.FnCustom.Function == "" {
 		return
 	}
-	h.MarshalAndPublish(*fn.dispatch)
+	h.MarshalAndPublish(fn.traceContext(), *fn.dispatch)
+}
+
+func (h handler) Keyed(fn FnComponent) {
+	if fn.dispatch.FnRender.TargetID == "" || fn.dispatch.FnKeyed.Key == "" {
+		return
+	}
+	fn.dispatch.FnRender.HTML = h.app.minify(fn.dispatch.FnRender.HTML)
+	h.MarshalAndPublish(fn.traceContext(), *fn.dispatch)
 }
 
-func (h handler) MarshalAndPublish(d Dispatch) {
+func (h handler) MarshalAndPublish(ctx context.Context, d Dispatch) {
+	_, span := h.app.tracer().Start(ctx, "fncmp.publish",
+		d.logFields("trace_id", d.TraceID, "function", d.Function)...)
+	defer span.End()
 	if d.conn == nil {
 		d.FnError.Message = "connection not found"
+		span.RecordError(ErrConnectionNotFound)
 		h.Error(d)
 		return
 	}
-	b, err := json.Marshal(d)
+	msgType, b, err := d.conn.encoding.marshal(d)
 	if err != nil {
 		d.FnError.Message = err.Error()
+		span.RecordError(err)
 		h.Error(d)
 		return
 	}
-	d.conn.Publish(b)
+	span.SetAttributes("bytes", len(b))
+	if err := d.conn.enqueue(d.coalesceKey(), msgType, b); err != nil {
+		span.RecordError(err)
+		return
+	}
+	h.app.inspect("out", &d)
+	d.conn.record("out", &d)
+	h.app.observe(func(hooks Hooks) { hooks.DispatchSent(string(d.Function)) })
 }
 
 func (h handler) Event(d Dispatch) {
@@ -147,7 +254,7 @@ func (h handler) Event(d Dispatch) {
 		h.Error(d)
 		return
 	}
-	listener, ok := evtListeners.Get(d.FnEvent.ID, d.conn)
+	listener, ok := h.app.listeners.Get(d.FnEvent.ID, d.conn)
 	if !ok {
 		d.FnError.Message = fmt.Sprintf("event listener with id '%s' not found", d.FnEvent.ID)
 		h.Error(d)
@@ -155,18 +262,41 @@ func (h handler) Event(d Dispatch) {
 	}
 	listener.Data = d.FnEvent.Data
 
+	// Clients send the trace ID, so it is replaced unless well formed
+	if !validTraceID(d.TraceID) {
+		d.TraceID = newTraceID()
+	}
+	tracer := h.app.tracer()
 	ctx := context.WithValue(listener.Context, EventKey, listener)
-	response := listener.Handler(ctx)
+	ctx = context.WithValue(ctx, TraceKey, d.TraceID)
+	ctx, span := tracer.Start(ctx, "fncmp.event", d.logFields("trace_id", d.TraceID, "on", listener.On)...)
+	defer span.End()
+	if !d.received.IsZero() {
+		span.SetAttributes("queue_wait", time.Since(d.received))
+	}
+
+	handleCtx, handleSpan := tracer.Start(ctx, "fncmp.handle", "on", listener.On)
+	start := time.Now()
+	response := listener.Handler(handleCtx)
+	elapsed := time.Since(start)
+	handleSpan.End()
+	h.app.observe(func(hooks Hooks) { hooks.Handled("event", string(listener.On), elapsed) })
+
 	response.dispatch.conn = d.conn
 	response.dispatch.HandlerID = d.HandlerID
-	h.out <- response
+	if response.dispatch.TraceID == "" {
+		response.dispatch.TraceID = d.TraceID
+	}
+	h.reply(response)
 }
 
 func (h handler) Error(d Dispatch) {
-	if config.Silent {
-		return
+	h.app.log.Error(d.FnError.Message, d.logFields()...)
+	// Errors sent by the client were already recorded when received
+	if d.Function != _error || d.received.IsZero() {
+		h.app.inspectError(&d)
 	}
-	config.Logger.Error(d.FnError)
+	h.app.observe(func(hooks Hooks) { hooks.Error(errorKindDispatch) })
 }
 
 type Writer struct {
@@ -179,39 +309,36 @@ func (w *Writer) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-func MiddleWareFn(h http.HandlerFunc, hf HandleFn) http.HandlerFunc {
-	handler := newHandler()
+// MiddleWareFn serves h for page requests and hf for the connections opened by
+// the client runtime on the same route
+func (a *App) MiddleWareFn(h http.HandlerFunc, hf HandleFn) http.HandlerFunc {
+	handler := a.newHandler()
+	handler.listen()
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.URL.Query().Get("fncmp_id")
 		if id == "" {
+			r = a.withCSP(w, r)
 			writer := Writer{ResponseWriter: w}
 			h(&writer, r)
-			w.Write(writer.buf)
+			page := writer.buf
+			if a.config.SSR {
+				page = a.serverRender(page, r, handler, hf)
+			}
+			w.Write(page)
+		} else if r.Method == http.MethodPost && r.URL.Query().Get(transportParam) == sseTransportName {
+			a.deliverPost(w, r, id)
+		} else if a.shuttingDown.Load() {
+			http.Error(w, ErrShuttingDown.Error(), http.StatusServiceUnavailable)
 		} else {
-			newConnection, err := newConn(w, r, handler.id, id)
+			// The conn renders hf once the client's handshake is accepted
+			newConnection, err := a.newConn(w, r, handler.id, id, hf)
 			if err != nil {
-				config.Logger.Error(ErrConnectionFailed)
-				config.Logger.Error(err)
+				a.log.Error(ErrConnectionFailed.Error(), "conn", id, "handler", handler.id, "error", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte(ErrConnectionFailed))
 				return
 			}
-			newConnection.HandlerID = handler.id
-
-			ctx := context.WithValue(r.Context(), dispatchKey, dispatchDetails{
-				ConnID:    id,
-				Conn:      newConnection,
-				HandlerID: handler.id,
-			})
-			ctx = context.WithValue(ctx, RequestKey, r)
-
-			fn := hf(ctx)
-			fn.dispatch.conn = newConnection
-			fn.dispatch.ConnID = id
-			fn.dispatch.HandlerID = handler.id
-			handler.out <- fn
-			handler.listen()
 			newConnection.listen()
 		}
 	}
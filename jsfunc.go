@@ -0,0 +1,121 @@
+package fncmp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// callTimeout bounds Call when its context has no deadline
+const callTimeout = 30 * time.Second
+
+// JSFunc is a client function taking A and returning R.
+//
+// The client only runs functions registered with the runtime, typically in a
+// script loaded after Script:
+//
+//	fncmp.register("confirm", (message) => window.confirm(message));
+//
+// and declared on the server with DeclareJS:
+//
+//	var confirm = fncmp.DeclareJS[string, bool]("confirm")
+type JSFunc[A, R any] struct {
+	Name string
+}
+
+// DeclareJS declares a client function registered with fncmp.register(name, fn)
+func DeclareJS[A, R any](name string) JSFunc[A, R] {
+	return JSFunc[A, R]{Name: name}
+}
+
+// Run runs the client function without waiting for its result
+func (f JSFunc[A, R]) Run(ctx context.Context, arg A) {
+	JS(ctx, f.Name, arg)
+}
+
+// Call runs the client function and waits for its result.
+//
+// Results of functions returning a Promise are awaited by the client. An error
+// is returned if the function is not registered, throws, or ctx is done first,
+// which is after callTimeout if ctx has no deadline.
+//
+// Call may be used in HandleFns and event listeners, which block their own
+// client's events until it returns. Calls made while a page is rendered with
+// Config.SSR time out, as the client is not connected yet.
+func (f JSFunc[A, R]) Call(ctx context.Context, arg A) (R, error) {
+	var r R
+	fn := NewFn(ctx, nil).JS(f.Name, arg)
+	c := fn.dispatch.conn
+	if c == nil {
+		return r, ErrNoClientConnection
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callTimeout)
+		defer cancel()
+	}
+	fn.dispatch.ID = uuid.New().String()
+	result := c.calls.add(fn.dispatch.ID)
+	defer c.calls.remove(fn.dispatch.ID)
+
+	fn.Dispatch()
+	select {
+	case d := <-result:
+		if d.Function == _error {
+			return r, errors.New(d.FnError.Message)
+		}
+		if len(d.FnCustom.Result) == 0 {
+			return r, nil
+		}
+		err := json.Unmarshal(d.FnCustom.Result, &r)
+		return r, err
+	case <-ctx.Done():
+		return r, ctx.Err()
+	case <-c.done:
+		return r, ErrConnectionClosed
+	}
+}
+
+// pendingCalls holds the calls of a conn waiting for a result from the client
+type pendingCalls struct {
+	mu    sync.Mutex
+	calls map[string]chan Dispatch
+}
+
+func (p *pendingCalls) add(id string) chan Dispatch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.calls == nil {
+		p.calls = make(map[string]chan Dispatch)
+	}
+	result := make(chan Dispatch, 1)
+	p.calls[id] = result
+	return result
+}
+
+func (p *pendingCalls) remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.calls, id)
+}
+
+// resolve passes a result or error from the client to its pending call,
+// returning false if the dispatch does not answer a call
+func (p *pendingCalls) resolve(d Dispatch) bool {
+	if d.ID == "" || (d.Function != custom && d.Function != _error) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result, ok := p.calls[d.ID]
+	if !ok {
+		return false
+	}
+	delete(p.calls, d.ID)
+	result <- d
+	return true
+}
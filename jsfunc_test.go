@@ -0,0 +1,82 @@
+package fncmp
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testConfirm = DeclareJS[string, bool]("confirm")
+
+// answer replies to the next JSFunc call sent to the client
+func (tc *testClient) answer(result any) {
+	tc.t.Helper()
+	d := tc.next()
+	if d.Function != custom || d.ID == "" {
+		tc.t.Fatalf("dispatch = %s, want a call", d.Function)
+	}
+	d.FnCustom.Result, _ = json.Marshal(result)
+	tc.send(d)
+}
+
+func TestCallInRootHandleFn(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		ok, err := testConfirm.Call(ctx, "continue?")
+		if err != nil {
+			return NewFn(ctx, Text(err.Error()))
+		}
+		return NewFn(ctx, Text(strconv.FormatBool(ok)))
+	})
+	tc.answer(true)
+	if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, ">true<") {
+		t.Errorf("render = %s, want the call's result", d.FnRender.HTML)
+	}
+}
+
+func TestCallBlocksOnlyItsClient(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	h := newTestHandler(t, app)
+	root := func(ctx context.Context) FnComponent {
+		return NewFn(ctx, HTML("button")).WithEvents(func(ctx context.Context) FnComponent {
+			if ok, _ := testConfirm.Call(ctx, "continue?"); ok {
+				return NewFn(ctx, Text("confirmed"))
+			}
+			return NewFn(ctx, Text("clicked"))
+		}, OnClick)
+	}
+	slow := newTestClient(t, h, root)
+	slow.nextRender()
+	fast := newTestClient(t, h, root)
+	fast.nextRender()
+
+	// The slow client never answers its call
+	slow.fire(slow.listener(OnClick))
+	if d := slow.next(); d.Function != custom {
+		t.Fatalf("dispatch = %s, want a call", d.Function)
+	}
+	fast.fire(fast.listener(OnClick))
+	fast.answer(false)
+	if d := fast.nextRender(); !strings.Contains(d.FnRender.HTML, "clicked") {
+		t.Errorf("render = %s", d.FnRender.HTML)
+	}
+}
+
+func TestCallContextDeadline(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	errs := make(chan error, 1)
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err := testConfirm.Call(ctx, "continue?")
+		errs <- err
+		return NewFn(ctx, nil)
+	})
+	tc.next()
+	if err := <-errs; err != context.DeadlineExceeded {
+		t.Errorf("Call error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,129 @@
+package fncmp
+
+import (
+	"context"
+	"io"
+	"regexp"
+)
+
+type keyedOp string
+
+const (
+	keyedInsert keyedOp = "insert"
+	keyedUpdate keyedOp = "update"
+	keyedMove   keyedOp = "move"
+	keyedRemove keyedOp = "remove"
+)
+
+// keyAttr is set on every item of a KeyedList to its key
+const keyAttr = "data-fncmp-key"
+
+// KeyedItem is an item of a KeyedList, Key must be unique within the list
+type KeyedItem struct {
+	Key       string
+	Component Component
+}
+
+// KeyedList renders items that can be inserted, updated, moved and removed
+// individually by key, without re-rendering the rest of the list:
+//
+//	rows := fncmp.KeyedList{ID: "orders", Tag: "tbody", ItemTag: "tr"}
+//
+//	// Initial render
+//	rows.Items = items
+//	return fncmp.NewFn(ctx, Table(rows))
+//
+//	// In an event handler
+//	return rows.Update(ctx, fncmp.KeyedItem{Key: order.ID, Component: OrderRow(order)})
+//
+// The operations return an FnComponent to return from a HandleFn or Dispatch.
+type KeyedList struct {
+	// ID is the ID of the list's element, operations target it
+	ID string
+	// Tag is the tag name of the list's element, "div" if empty or invalid
+	Tag string
+	// ItemTag is the tag name of the element wrapping each item, "div" if
+	// empty or invalid
+	ItemTag string
+	// Items are rendered by Render
+	Items []KeyedItem
+}
+
+// Render renders the list and its Items
+func (l KeyedList) Render(ctx context.Context, w io.Writer) error {
+	tag := orDiv(l.Tag)
+	io.WriteString(w, "<"+tag+attr("id", l.ID)+">")
+	for _, item := range l.Items {
+		if err := l.renderItem(ctx, w, item); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "</"+tag+">")
+	return err
+}
+
+// Insert inserts an item before the item at index, or at the end of the list
+// if index is negative or past the end. An item with the same key is replaced.
+func (l KeyedList) Insert(ctx context.Context, index int, item KeyedItem) FnComponent {
+	f := l.op(ctx, keyedInsert, item.Key)
+	f.dispatch.FnKeyed.Index = index
+	return l.withItem(f, item)
+}
+
+// Update replaces the item with the same key
+func (l KeyedList) Update(ctx context.Context, item KeyedItem) FnComponent {
+	return l.withItem(l.op(ctx, keyedUpdate, item.Key), item)
+}
+
+// Move moves the item with key before the item at index, or to the end of
+// the list if index is negative or past the end. The index is counted
+// without the moved item.
+func (l KeyedList) Move(ctx context.Context, key string, index int) FnComponent {
+	f := l.op(ctx, keyedMove, key)
+	f.dispatch.FnKeyed.Index = index
+	return f
+}
+
+// Remove removes the item with key
+func (l KeyedList) Remove(ctx context.Context, key string) FnComponent {
+	return l.op(ctx, keyedRemove, key)
+}
+
+func (l KeyedList) op(ctx context.Context, op keyedOp, key string) FnComponent {
+	f := NewFn(ctx, nil)
+	f.dispatch.Function = keyed
+	f.dispatch.FnRender.TargetID = l.ID
+	f.dispatch.FnRender.Tag = ""
+	f.dispatch.FnRender.Inner = false
+	f.dispatch.FnKeyed = FnKeyed{Op: op, Key: key}
+	return f
+}
+
+// withItem renders the item into the dispatch without the FnComponent's
+// wrapper, so items may be elements such as table rows
+func (l KeyedList) withItem(f FnComponent, item KeyedItem) FnComponent {
+	var w Writer
+	l.renderItem(f.Context, &w, item)
+	f.dispatch.FnRender.HTML = string(w.buf)
+	return f
+}
+
+func (l KeyedList) renderItem(ctx context.Context, w io.Writer, item KeyedItem) error {
+	tag := orDiv(l.ItemTag)
+	io.WriteString(w, "<"+tag+attr(keyAttr, item.Key)+">")
+	if err := renderOptional(ctx, w, item.Component); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "</"+tag+">")
+	return err
+}
+
+// tagName matches the tag names KeyedList accepts, so they cannot inject attributes
+var tagName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
+
+func orDiv(tag string) string {
+	if !tagName.MatchString(tag) {
+		return "div"
+	}
+	return tag
+}
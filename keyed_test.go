@@ -0,0 +1,113 @@
+package fncmp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestKeyedListRender(t *testing.T) {
+	items := []KeyedItem{{Key: "a", Component: HTML("A")}, {Key: "b", Component: HTML("B")}}
+	tests := []struct {
+		name string
+		list KeyedList
+		want string
+	}{
+		{
+			name: "default tags",
+			list: KeyedList{ID: "list", Items: items},
+			want: `<div id="list"><div data-fncmp-key="a">A</div><div data-fncmp-key="b">B</div></div>`,
+		},
+		{
+			name: "table",
+			list: KeyedList{ID: "list", Tag: "tbody", ItemTag: "tr", Items: items},
+			want: `<tbody id="list"><tr data-fncmp-key="a">A</tr><tr data-fncmp-key="b">B</tr></tbody>`,
+		},
+		{
+			name: "invalid tags",
+			list: KeyedList{ID: "list", Tag: "tbody onclick=alert(1)", ItemTag: `tr"`, Items: items},
+			want: `<div id="list"><div data-fncmp-key="a">A</div><div data-fncmp-key="b">B</div></div>`,
+		},
+		{
+			name: "empty",
+			list: KeyedList{ID: "list"},
+			want: `<div id="list"></div>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderComponent(tt.list); got != tt.want {
+				t.Errorf("rendered %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyedListOps(t *testing.T) {
+	ctx := context.WithValue(context.Background(), dispatchKey, dispatchDetails{})
+	rows := KeyedList{ID: "rows", Tag: "tbody", ItemTag: "tr"}
+	item := KeyedItem{Key: "k", Component: HTML("<td>row</td>")}
+	tests := []struct {
+		name  string
+		fn    FnComponent
+		op    keyedOp
+		index int
+		html  string
+	}{
+		{
+			name:  "insert",
+			fn:    rows.Insert(ctx, 2, item),
+			op:    keyedInsert,
+			index: 2,
+			html:  `<tr data-fncmp-key="k"><td>row</td></tr>`,
+		},
+		{
+			name: "update",
+			fn:   rows.Update(ctx, item),
+			op:   keyedUpdate,
+			html: `<tr data-fncmp-key="k"><td>row</td></tr>`,
+		},
+		{
+			name:  "move",
+			fn:    rows.Move(ctx, "k", -1),
+			op:    keyedMove,
+			index: -1,
+		},
+		{
+			name: "remove",
+			fn:   rows.Remove(ctx, "k"),
+			op:   keyedRemove,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.fn.dispatch
+			if d.Function != keyed || d.FnRender.TargetID != "rows" || d.FnRender.Tag != "" {
+				t.Errorf("dispatch %s to target %q tag %q, want keyed to rows", d.Function, d.FnRender.TargetID, d.FnRender.Tag)
+			}
+			if want := (FnKeyed{Op: tt.op, Key: "k", Index: tt.index}); d.FnKeyed != want {
+				t.Errorf("FnKeyed = %+v, want %+v", d.FnKeyed, want)
+			}
+			// Items are sent without the FnComponent wrapper, so rows stay rows
+			if d.FnRender.HTML != tt.html {
+				t.Errorf("HTML = %q, want %q", d.FnRender.HTML, tt.html)
+			}
+		})
+	}
+}
+
+func TestKeyedListDispatch(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	rows := KeyedList{ID: "rows"}
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		return NewFn(ctx, HTML("button")).WithEvents(func(ctx context.Context) FnComponent {
+			return rows.Update(ctx, KeyedItem{Key: "k", Component: HTML("updated")})
+		}, OnClick)
+	})
+	tc.nextRender()
+	tc.fire(tc.listener(OnClick))
+	d := tc.next()
+	if d.Function != keyed || d.FnKeyed.Key != "k" || !strings.Contains(d.FnRender.HTML, "updated") {
+		t.Errorf("dispatch = %s %+v %q, want a keyed update", d.Function, d.FnKeyed, d.FnRender.HTML)
+	}
+}
@@ -0,0 +1,26 @@
+package fncmp
+
+import "context"
+
+// Lazy renders a placeholder that is replaced by the component returned by
+// load once the client scrolls it into view.
+//
+// It is an FnComponent listening for OnVisible, so load is called by the
+// handler like any other event listener, and it can be rendered inside other
+// components:
+//
+//	chart := fncmp.Lazy(ctx, fncmp.HTML("<p>Loading chart...</p>"), Chart)
+//	return fncmp.NewFn(ctx, Dashboard(chart))
+//
+// Without a connection, such as when rendered by RenderComponent, the
+// component is loaded right away.
+func Lazy(ctx context.Context, placeholder Component, load func(ctx context.Context) Component) FnComponent {
+	if dd, ok := ctx.Value(dispatchKey).(dispatchDetails); !ok || dd.Conn == nil {
+		return NewFn(ctx, load(ctx))
+	}
+	f := NewFn(ctx, placeholder)
+	id := f.id
+	return f.WithEvents(func(ctx context.Context) FnComponent {
+		return NewFn(ctx, load(ctx)).SwapElementOuter(id)
+	}, OnVisible)
+}
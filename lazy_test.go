@@ -0,0 +1,33 @@
+package fncmp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLazyWithoutConn(t *testing.T) {
+	html := RenderComponent(Lazy(context.Background(), HTML("loading"), func(ctx context.Context) Component {
+		return HTML("loaded")
+	}))
+	if !strings.Contains(html, "loaded") || strings.Contains(html, "loading") {
+		t.Errorf("RenderComponent(Lazy) = %q, want the loaded component", html)
+	}
+}
+
+func TestLazyVisible(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		return Lazy(ctx, HTML("loading"), func(ctx context.Context) Component {
+			return HTML("loaded")
+		})
+	})
+	if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "loading") {
+		t.Fatalf("root render = %q, want the placeholder", d.FnRender.HTML)
+	}
+	tc.fire(tc.listener(OnVisible))
+	d := tc.nextRender()
+	if !d.FnRender.Outer || !strings.Contains(d.FnRender.HTML, "loaded") {
+		t.Errorf("visible render = %+v, want the loaded component swapped in", d.FnRender)
+	}
+}
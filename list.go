@@ -0,0 +1,98 @@
+package fncmp
+
+import (
+	"context"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// PageLoader returns the page of items after cursor, and the cursor of the
+// next page or "" if it is the last. The first page is loaded with an empty cursor.
+type PageLoader[T any] func(ctx context.Context, cursor string) (items []T, next string, err error)
+
+// InfiniteList renders a list one page at a time, loading the next page when
+// the client scrolls to the end of the list:
+//
+//	fncmp.InfiniteList[Post]{
+//		Load:    posts.Page,
+//		Item:    func(p Post) fncmp.Component { return PostCard(p) },
+//		Loading: fncmp.HTML("<p>Loading...</p>"),
+//		End:     fncmp.HTML("<p>You're all caught up</p>"),
+//	}
+//
+// The first page is rendered with the list, later pages are appended as a
+// Lazy sentinel after the list becomes visible. Loading more pages requires
+// a connection, so only the first page is rendered without one.
+type InfiniteList[T any] struct {
+	// Load returns the pages of the list
+	Load PageLoader[T]
+	// Item renders an item of the list
+	Item func(item T) Component
+	// Loading is rendered at the end of the list while the next page loads
+	Loading Component
+	// End is rendered after the last page, nothing if nil
+	End Component
+	// Error is rendered in place of the next page if it fails to load,
+	// nothing if nil
+	Error func(err error) Component
+}
+
+// Render renders the first page of the list
+func (l InfiniteList[T]) Render(ctx context.Context, w io.Writer) error {
+	id := "fncmp-list-" + uuid.New().String()
+	items, next, err := l.Load(ctx, "")
+
+	io.WriteString(w, "<div"+attr("id", id)+">")
+	renderOptional(ctx, w, listPage[T]{items: items, item: l.Item})
+	io.WriteString(w, "</div>")
+
+	if dd, ok := ctx.Value(dispatchKey).(dispatchDetails); !ok || dd.Conn == nil {
+		return err
+	}
+	return renderOptional(ctx, w, l.after(ctx, id, next, err))
+}
+
+// after returns what follows a page: the sentinel loading the next page, End or Error
+func (l InfiniteList[T]) after(ctx context.Context, id string, next string, err error) Component {
+	switch {
+	case err != nil:
+		if l.Error == nil {
+			return nil
+		}
+		return l.Error(err)
+	case next == "":
+		return l.End
+	default:
+		return Lazy(ctx, l.Loading, func(ctx context.Context) Component {
+			items, cursor, err := l.Load(ctx, next)
+			if err == nil {
+				NewFn(ctx, listPage[T]{items: items, item: l.Item}).AppendElement(id).Dispatch()
+			}
+			if c := l.after(ctx, id, cursor, err); c != nil {
+				return c
+			}
+			// A swap without HTML is not sent, so the sentinel is replaced
+			// with an empty element
+			return listEnd
+		})
+	}
+}
+
+// listEnd replaces the sentinel after the last page when there is no End or Error
+const listEnd = HTML("<div hidden></div>")
+
+// listPage renders the items of a page
+type listPage[T any] struct {
+	items []T
+	item  func(item T) Component
+}
+
+func (p listPage[T]) Render(ctx context.Context, w io.Writer) error {
+	for _, item := range p.items {
+		if err := renderOptional(ctx, w, p.item(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
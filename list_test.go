@@ -0,0 +1,110 @@
+package fncmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// pages returns a PageLoader of n pages with one item each, failing with err
+// instead of returning the last page if err is set
+func pages(n int, err error) PageLoader[int] {
+	return func(ctx context.Context, cursor string) ([]int, string, error) {
+		var page int
+		if cursor != "" {
+			fmt.Sscan(cursor, &page)
+		}
+		if page == n-1 && err != nil {
+			return nil, "", err
+		}
+		if page == n-1 {
+			return []int{page}, "", nil
+		}
+		return []int{page}, fmt.Sprint(page + 1), nil
+	}
+}
+
+// markup matches the tags of rendered HTML, leaving its text
+var markup = regexp.MustCompile(`<[^>]*>`)
+
+// nextSentinel fires the sentinel's visible listener that was not fired yet
+func (tc *testClient) nextSentinel(fired map[string]bool) {
+	tc.t.Helper()
+	for _, el := range tc.conn.app.listeners.List(tc.conn) {
+		if el.On == OnVisible && !fired[el.ID] {
+			fired[el.ID] = true
+			tc.fire(el)
+			return
+		}
+	}
+	tc.t.Fatal("no sentinel to fire")
+}
+
+func TestInfiniteList(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name string
+		err  error
+		list InfiniteList[int]
+		want string
+	}{
+		{
+			name: "end",
+			list: InfiniteList[int]{End: HTML("end")},
+			want: "end",
+		},
+		{
+			name: "nil end",
+		},
+		{
+			name: "error",
+			err:  failed,
+			list: InfiniteList[int]{Error: func(err error) Component { return HTML(err.Error()) }},
+			want: "failed",
+		},
+		{
+			name: "nil error",
+			err:  failed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.list.Load = pages(3, tt.err)
+			tt.list.Item = func(i int) Component { return HTML(fmt.Sprintf("<p>item %d</p>", i)) }
+			tt.list.Loading = HTML("loading")
+			app := NewApp(&Config{Silent: true})
+			tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+				return NewFn(ctx, tt.list)
+			})
+			d := tc.nextRender()
+			if !strings.Contains(d.FnRender.HTML, "item 0") || !strings.Contains(d.FnRender.HTML, "loading") {
+				t.Fatalf("first page = %q, want item 0 and the sentinel", d.FnRender.HTML)
+			}
+
+			fired := map[string]bool{}
+			tc.nextSentinel(fired)
+			if d := tc.nextRender(); !d.FnRender.Append || !strings.Contains(d.FnRender.HTML, "item 1") {
+				t.Fatalf("second page = %+v, want item 1 appended", d.FnRender)
+			}
+			if d := tc.nextRender(); !d.FnRender.Outer || !strings.Contains(d.FnRender.HTML, "loading") {
+				t.Fatalf("second sentinel = %+v, want the sentinel swapped in", d.FnRender)
+			}
+
+			tc.nextSentinel(fired)
+			d = tc.nextRender()
+			if tt.err == nil {
+				if !d.FnRender.Append || !strings.Contains(d.FnRender.HTML, "item 2") {
+					t.Fatalf("last page = %+v, want item 2 appended", d.FnRender)
+				}
+				d = tc.nextRender()
+			}
+			// The sentinel is replaced by End or Error, or removed if they are nil
+			if text := markup.ReplaceAllString(d.FnRender.HTML, ""); !d.FnRender.Outer || text != tt.want {
+				t.Errorf("last sentinel replaced by %q (%+v), want %q", text, d.FnRender, tt.want)
+			}
+		})
+	}
+}
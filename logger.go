@@ -0,0 +1,83 @@
+package fncmp
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Logger is implemented by the structured loggers fncmp writes to.
+//
+// *slog.Logger implements Logger. Loggers implementing slog.Handler, such as
+// charmbracelet/log, can be used by wrapping them with slog.New.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// defaultLogger writes text logs to stderr, levels are applied by leveledLogger
+func defaultLogger() Logger {
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+	return slog.New(handler).With("lib", "fncmp")
+}
+
+// leveledLogger applies Config.LogLevel and Config.Silent to any Logger
+type leveledLogger struct {
+	logger Logger
+	level  LogLevel
+	silent bool
+}
+
+func (a *App) configureLogger() {
+	a.log = leveledLogger{
+		logger: a.config.Logger,
+		level:  a.config.LogLevel,
+		silent: a.config.Silent,
+	}
+}
+
+func (l leveledLogger) enabled(level LogLevel) bool {
+	return !l.silent && level >= l.level
+}
+
+func (l leveledLogger) Debug(msg string, args ...any) {
+	if l.enabled(Debug) {
+		l.logger.Debug(msg, args...)
+	}
+}
+
+func (l leveledLogger) Info(msg string, args ...any) {
+	if l.enabled(Info) {
+		l.logger.Info(msg, args...)
+	}
+}
+
+func (l leveledLogger) Warn(msg string, args ...any) {
+	if l.enabled(Warn) {
+		l.logger.Warn(msg, args...)
+	}
+}
+
+func (l leveledLogger) Error(msg string, args ...any) {
+	if l.enabled(Error) {
+		l.logger.Error(msg, args...)
+	}
+}
+
+// logFields returns the structured fields identifying a dispatch in logs
+func (d *Dispatch) logFields(args ...any) []any {
+	connID := d.ConnID
+	if d.conn != nil {
+		connID = d.conn.ID
+	}
+	fields := []any{
+		"conn", connID,
+		"handler", d.HandlerID,
+		"listener", d.FnEvent.ID,
+		"label", d.Label,
+	}
+	return append(fields, args...)
+}
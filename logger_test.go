@@ -0,0 +1,89 @@
+package fncmp
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logLine is a call made to a testLogger
+type logLine struct {
+	level string
+	msg   string
+	args  []any
+}
+
+// testLogger records the calls made to it
+type testLogger struct {
+	lines []logLine
+}
+
+func (l *testLogger) log(level, msg string, args []any) {
+	l.lines = append(l.lines, logLine{level: level, msg: msg, args: args})
+}
+
+func (l *testLogger) Debug(msg string, args ...any) { l.log("debug", msg, args) }
+func (l *testLogger) Info(msg string, args ...any)  { l.log("info", msg, args) }
+func (l *testLogger) Warn(msg string, args ...any)  { l.log("warn", msg, args) }
+func (l *testLogger) Error(msg string, args ...any) { l.log("error", msg, args) }
+
+func TestLogLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  LogLevel
+		silent bool
+		want   []string
+	}{
+		{name: "debug", level: Debug, want: []string{"debug", "info", "warn", "error"}},
+		{name: "warn", level: Warn, want: []string{"warn", "error"}},
+		{name: "error", level: Error, want: []string{"error"}},
+		{name: "none", level: None},
+		{name: "silent", level: Debug, silent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &testLogger{}
+			app := NewApp(&Config{Logger: logger, LogLevel: tt.level, Silent: tt.silent})
+			logger.lines = nil
+			app.log.Debug("msg")
+			app.log.Info("msg")
+			app.log.Warn("msg")
+			app.log.Error("msg")
+			var got []string
+			for _, line := range logger.lines {
+				got = append(got, line.level)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("logged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	app := NewApp(&Config{Logger: logger, LogLevel: Error})
+
+	d := Dispatch{
+		ConnID:    "conn",
+		HandlerID: "handler",
+		Label:     "label",
+		FnEvent:   EventListener{ID: "listener"},
+	}
+	app.log.Error("failed", d.logFields("extra", 1)...)
+	line := buf.String()
+	for _, want := range []string{
+		"msg=failed",
+		"conn=conn",
+		"handler=handler",
+		"listener=listener",
+		"label=label",
+		"extra=1",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line %q missing %q", line, want)
+		}
+	}
+}
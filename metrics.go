@@ -0,0 +1,235 @@
+package fncmp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hooks receives instrumentation events from an App, see Config.Hooks.
+//
+// Hooks are called synchronously from the connection and handler goroutines,
+// so implementations must be safe for concurrent use and return quickly.
+type Hooks interface {
+	// ConnOpened is called when a client connection is established
+	ConnOpened(connID, handlerID string)
+	// ConnClosed is called once when a client connection is closed
+	ConnClosed(connID, handlerID string)
+	// DispatchReceived is called for every valid dispatch read from a client
+	DispatchReceived(function string)
+	// DispatchSent is called for every dispatch queued for a client
+	DispatchSent(function string)
+	// Handled is called after a HandleFn ("handlefn"), an event
+	// listener's handler ("event") or a timer ("timer") returns
+	Handled(kind, label string, elapsed time.Duration)
+	// Error is called when a dispatch fails or a client is rejected
+	Error(kind string)
+}
+
+// Error kinds reported to Hooks.Error
+const (
+	errorKindDispatch  = "dispatch"
+	errorKindHandshake = "handshake"
+	errorKindSignature = "signature"
+	errorKindRateLimit = "rate_limit"
+	errorKindOverflow  = "overflow"
+	errorKindWrite     = "write"
+)
+
+// observe calls fn with the App's built-in metrics and Config.Hooks, if set
+func (a *App) observe(fn func(Hooks)) {
+	fn(a.metrics)
+	if hooks := a.config.Hooks; hooks != nil {
+		fn(hooks)
+	}
+}
+
+// latencyBuckets are the upper bounds, in seconds, of the handler latency histograms
+var latencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type dispatchCount struct {
+	direction string
+	function  string
+}
+
+// metrics is the App's built-in Hooks, exported by MetricsHandler
+type metrics struct {
+	mu         sync.Mutex
+	opened     uint64
+	closed     uint64
+	dropped    uint64
+	dispatches map[dispatchCount]uint64
+	handled    map[string]*histogram
+	errors     map[string]uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		dispatches: make(map[dispatchCount]uint64),
+		handled:    make(map[string]*histogram),
+		errors:     make(map[string]uint64),
+	}
+}
+
+func (m *metrics) ConnOpened(connID, handlerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opened++
+}
+
+func (m *metrics) ConnClosed(connID, handlerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed++
+}
+
+// queueDropped counts a message dropped from a conn's outbound queue
+func (m *metrics) queueDropped() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped++
+}
+
+func (m *metrics) DispatchReceived(function string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dispatches[dispatchCount{"in", function}]++
+}
+
+func (m *metrics) DispatchSent(function string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dispatches[dispatchCount{"out", function}]++
+}
+
+// Handled records latency by kind only, as labels are unbounded
+func (m *metrics) Handled(kind, label string, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.handled[kind]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.handled[kind] = h
+	}
+	h.observe(elapsed.Seconds())
+}
+
+func (m *metrics) Error(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[kind]++
+}
+
+// MetricsHandler returns the default App's MetricsHandler
+func MetricsHandler() http.Handler {
+	return defaultApp.MetricsHandler()
+}
+
+// MetricsHandler returns an http.Handler reporting the App's metrics in the
+// Prometheus text exposition format:
+//
+//	http.Handle("/metrics", fncmp.MetricsHandler())
+func (a *App) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		a.writeMetrics(w)
+	})
+}
+
+func (a *App) writeMetrics(w io.Writer) {
+	// Queues are reported in total, as conn IDs are chosen by clients
+	queues := a.Queues()
+	depth := 0
+	for _, q := range queues {
+		depth += q.Depth
+	}
+
+	m := a.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metricHeader(w, "fncmp_connections_open", "gauge", "Open client connections.")
+	fmt.Fprintf(w, "fncmp_connections_open %d\n", len(queues))
+	metricHeader(w, "fncmp_connections_opened_total", "counter", "Client connections opened.")
+	fmt.Fprintf(w, "fncmp_connections_opened_total %d\n", m.opened)
+	metricHeader(w, "fncmp_connections_closed_total", "counter", "Client connections closed.")
+	fmt.Fprintf(w, "fncmp_connections_closed_total %d\n", m.closed)
+
+	metricHeader(w, "fncmp_dispatches_total", "counter", "Dispatches by direction and function.")
+	keys := make([]dispatchCount, 0, len(m.dispatches))
+	for k := range m.dispatches {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].direction != keys[j].direction {
+			return keys[i].direction < keys[j].direction
+		}
+		return keys[i].function < keys[j].function
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "fncmp_dispatches_total{direction=%s,function=%s} %d\n",
+			labelValue(k.direction), labelValue(k.function), m.dispatches[k])
+	}
+
+	metricHeader(w, "fncmp_handler_duration_seconds", "histogram", "Latency of HandleFns and event handlers.")
+	for _, kind := range sortedKeys(m.handled) {
+		h := m.handled[kind]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "fncmp_handler_duration_seconds_bucket{kind=%s,le=\"%g\"} %d\n",
+				labelValue(kind), bound, h.counts[i])
+		}
+		fmt.Fprintf(w, "fncmp_handler_duration_seconds_bucket{kind=%s,le=\"+Inf\"} %d\n", labelValue(kind), h.count)
+		fmt.Fprintf(w, "fncmp_handler_duration_seconds_sum{kind=%s} %g\n", labelValue(kind), h.sum)
+		fmt.Fprintf(w, "fncmp_handler_duration_seconds_count{kind=%s} %d\n", labelValue(kind), h.count)
+	}
+
+	metricHeader(w, "fncmp_queue_depth", "gauge", "Messages waiting in the outbound queues of all connections.")
+	fmt.Fprintf(w, "fncmp_queue_depth %d\n", depth)
+	metricHeader(w, "fncmp_queue_dropped_total", "counter", "Messages dropped from outbound queues.")
+	fmt.Fprintf(w, "fncmp_queue_dropped_total %d\n", m.dropped)
+
+	metricHeader(w, "fncmp_errors_total", "counter", "Errors by kind.")
+	for _, kind := range sortedKeys(m.errors) {
+		fmt.Fprintf(w, "fncmp_errors_total{kind=%s} %d\n", labelValue(kind), m.errors[kind])
+	}
+}
+
+func metricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue quotes and escapes a Prometheus label value
+func labelValue(v string) string {
+	return `"` + labelReplacer.Replace(v) + `"`
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
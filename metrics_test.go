@@ -0,0 +1,103 @@
+package fncmp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMetricsQueueTotals(t *testing.T) {
+	app := NewApp(&Config{Silent: true, SendBuffer: 1})
+	c := app.makeConn(httptest.NewRequest(http.MethodGet, "/", nil), "handler", "client-chosen-id", nil)
+	app.conns.Set(c.ID, c)
+	for i := 0; i < 3; i++ {
+		c.enqueue("", websocket.TextMessage, []byte("msg"))
+	}
+
+	var w strings.Builder
+	app.writeMetrics(&w)
+	out := w.String()
+	for _, want := range []string{"fncmp_queue_depth 1\n", "fncmp_queue_dropped_total 2\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "client-chosen-id") {
+		t.Errorf("metrics labelled with a conn ID:\n%s", out)
+	}
+
+	// Drops are still counted once the conn is gone
+	app.conns.Delete(c.ID)
+	w.Reset()
+	app.writeMetrics(&w)
+	if out := w.String(); !strings.Contains(out, "fncmp_queue_dropped_total 2\n") ||
+		!strings.Contains(out, "fncmp_queue_depth 0\n") {
+		t.Errorf("metrics after close:\n%s", out)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	tc := newTestClient(t, newTestHandler(t, app), clickCounter)
+	tc.nextRender()
+	tc.fire(tc.listener(OnClick))
+	tc.nextRender()
+
+	rec := httptest.NewRecorder()
+	app.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		"fncmp_connections_open 1\n",
+		"fncmp_connections_opened_total 1\n",
+		`fncmp_dispatches_total{direction="in",function="event"} 1`,
+		`fncmp_handler_duration_seconds_count{kind="event"} 1`,
+		`fncmp_handler_duration_seconds_count{kind="handlefn"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestMetricsDispatchSent(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy OverflowPolicy
+		close  bool
+		want   int
+	}{
+		{name: "queued", policy: DropOldest, want: 2},
+		{name: "overflow", policy: DisconnectSlow, want: 1},
+		{name: "closed", policy: DropOldest, close: true, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp(&Config{Silent: true, SendBuffer: 1, OverflowPolicy: tt.policy})
+			h := newTestHandler(t, app)
+			c := app.makeConn(httptest.NewRequest(http.MethodGet, "/", nil), h.id, "conn", nil)
+			c.transport = newMemTransport()
+			if tt.close {
+				c.close()
+			}
+			for i := 0; i < 2; i++ {
+				d := newDispatch("key")
+				d.conn = c
+				d.Function = render
+				h.MarshalAndPublish(context.Background(), *d)
+			}
+
+			var w strings.Builder
+			app.writeMetrics(&w)
+			want := fmt.Sprintf(`fncmp_dispatches_total{direction="out",function="render"} %d`, tt.want)
+			if out := w.String(); tt.want > 0 && !strings.Contains(out, want) ||
+				tt.want == 0 && strings.Contains(out, `direction="out"`) {
+				t.Errorf("metrics do not have %s:\n%s", want, out)
+			}
+		})
+	}
+}
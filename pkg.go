@@ -1,14 +1,12 @@
 package fncmp
 
 import (
+	"log/slog"
 	"math"
-	"os"
-	"time"
-
-	"github.com/charmbracelet/log"
 )
 
-type LogLevel log.Level
+// LogLevel is the minimum level of the logs written to Config.Logger
+type LogLevel slog.Level
 
 const (
 	Debug LogLevel = -4
@@ -19,31 +17,108 @@ const (
 	None  LogLevel = math.MaxInt32
 )
 
-var config = &Config{
-	Silent:   false,
-	LogLevel: Error,
-	DevMode:  false,
-	Logger: log.NewWithOptions(os.Stderr, log.Options{
-		ReportCaller:    true,
-		ReportTimestamp: true,
-		TimeFormat:      time.Kitchen,
-		Prefix:          "FnCmp: ",
-	}),
+func defaultConfig() *Config {
+	return &Config{
+		Silent:         false,
+		LogLevel:       Error,
+		DevMode:        false,
+		SendBuffer:     defaultSendBuffer,
+		OverflowPolicy: DropOldest,
+		ClientPath:     defaultClientPath,
+		Secret:         newSecret(),
+		Logger:         defaultLogger(),
+	}
 }
 
 type Config struct {
+	// DevMode enables development tools such as the inspector served by App.Handler
 	DevMode  bool
 	Silent   bool
 	LogLevel LogLevel
-	Logger   *log.Logger
+	// Logger receives structured logs at or above LogLevel unless Silent is set
+	Logger Logger
+	// SendBuffer is the number of outbound messages queued per connection
+	SendBuffer int
+	// OverflowPolicy determines what happens when a connection's SendBuffer is full
+	OverflowPolicy OverflowPolicy
+	// Compression enables permessage-deflate for clients that negotiate it
+	Compression bool
+	// ClientPath is where the App's Handler is mounted, "/_fncmp/" by default
+	ClientPath string
+	// CSP is the Content Security Policy set on pages served by MiddleWareFn,
+	// "{nonce}" is replaced with a new nonce for each request. Only the
+	// library's Script carries the nonce, see Nonce and StrictCSP.
+	CSP string
+	// PreserveWhitespace disables collapsing insignificant whitespace in rendered HTML
+	PreserveWhitespace bool
+	// MaxMessageSize limits the size in bytes of messages received from clients, 0 for no limit
+	MaxMessageSize int64
+	// RateLimit limits the messages received from clients
+	RateLimit RateLimit
+	// Secret signs the event listeners sent to clients, a random secret is
+	// generated if not set. Set it to keep signatures valid across restarts.
+	Secret []byte
+	// Hooks receives instrumentation events in addition to the App's MetricsHandler
+	Hooks Hooks
+	// Tracer traces the round trip of events, nothing is traced if not set
+	Tracer Tracer
+	// Recorder records the dispatches of every connection for Replay, nothing
+	// is recorded if not set. See NewFileRecorder.
+	Recorder Recorder
+	// Redact is called with every dispatch before it is recorded, to remove
+	// sensitive user input. The dispatch is a copy, but its event data is
+	// shared with the handler, so replace it rather than modifying it.
+	Redact func(d *RecordedDispatch)
+	// SSR renders the HandleFn into the page's MainTag during the HTTP
+	// response, the client attaches its event listeners when it connects
+	SSR bool
+	// MaxPending limits the server rendered pages waiting for their client to
+	// connect, pages over it are served without SSR. 1024 if not set.
+	MaxPending int
+	// TimerJitter randomizes the delays of Every and After by up to this
+	// fraction of them, 0.1 spreads them by ±10%. Timers are exact if 0, it
+	// must be less than 1.
+	TimerJitter float64
 }
 
+// Set sets the config of the default App
 func (c *Config) Set() {
+	defaultApp.Configure(c)
+}
+
+// Configure replaces the App's config
+func (a *App) Configure(c *Config) {
 	if c.Logger == nil {
-		c.Logger = config.Logger
+		c.Logger = a.config.Logger
+	}
+	if len(c.Secret) == 0 {
+		c.Secret = a.config.Secret
 	}
 
-	config = c
-	c.Logger.Info("FnCmp config set")
-	config.Logger.SetLevel(log.Level(c.LogLevel))
+	a.config = c
+	a.configureLogger()
+	a.validate(c)
+	a.log.Info("config set")
+}
+
+// validate resets config values that cannot be used to their defaults
+func (a *App) validate(c *Config) {
+	rates := []struct {
+		field string
+		rate  *Rate
+	}{
+		{"RateLimit.Conn", &c.RateLimit.Conn},
+		{"RateLimit.Listener", &c.RateLimit.Listener},
+	}
+	for _, r := range rates {
+		if !r.rate.valid() {
+			a.log.Warn(ErrInvalidConfig.Error(), "field", r.field, "events", r.rate.Events, "per", r.rate.Per)
+			*r.rate = Rate{}
+		}
+	}
+	// A jitter of 1 or more could make a timer's delay zero or negative
+	if c.TimerJitter < 0 || c.TimerJitter >= 1 {
+		a.log.Warn(ErrInvalidConfig.Error(), "field", "TimerJitter", "value", c.TimerJitter)
+		c.TimerJitter = 0
+	}
 }
@@ -0,0 +1,136 @@
+package fncmp
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolVersion is the version of the dispatch protocol spoken by the server.
+//
+// Clients must send it in their handshake, connections from clients speaking
+// another version are rejected.
+const ProtocolVersion = 1
+
+// handshakeTimeout bounds how long a client may take to send its handshake
+const handshakeTimeout = 10 * time.Second
+
+// maxCloseReason is the longest reason a WebSocket close frame can carry
+const maxCloseReason = 123
+
+// Client capabilities and server features exchanged during the handshake
+const (
+	// FeatureBinary indicates BinaryEncoding is supported
+	FeatureBinary = "binary"
+	// FeatureCompression indicates permessage-deflate is enabled
+	FeatureCompression = "compression"
+	// FeatureSSE indicates the Server-Sent Events transport is available
+	FeatureSSE = "sse"
+	// FeatureReload indicates DevMode, the client reconnects when the connection
+	// is lost and reloads the page when the server's BuildID changes
+	FeatureReload = "reload"
+)
+
+// buildID identifies this run of the server, so clients can detect restarts
+var buildID = newTraceID()[:16]
+
+// FnHandshake is the first dispatch exchanged on every connection.
+//
+// The client sends its protocol version and capabilities, and the server
+// replies with its version and enabled features.
+type FnHandshake struct {
+	Version      int      `json:"version"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	Features     []string `json:"features,omitempty"`
+	// BuildID changes every time the server is started
+	BuildID string `json:"build_id,omitempty"`
+}
+
+func (h FnHandshake) has(capability string) bool {
+	for _, c := range h.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// features lists the features the App offers to clients
+func (a *App) features() []string {
+	features := []string{FeatureBinary, FeatureSSE}
+	if a.config.Compression {
+		features = append(features, FeatureCompression)
+	}
+	if a.config.DevMode {
+		features = append(features, FeatureReload)
+	}
+	return features
+}
+
+// handshake validates the client's handshake, negotiates the conn's encoding
+// and replies with the server's handshake
+func (c *conn) handshake(message []byte) error {
+	var d Dispatch
+	if err := json.Unmarshal(message, &d); err != nil {
+		return fmt.Errorf("%w: %v", ErrHandshakeRequired, err)
+	}
+	if d.Function != handshake {
+		return ErrHandshakeRequired
+	}
+	if d.FnHandshake.Version != ProtocolVersion {
+		return fmt.Errorf("%w: client speaks version %d, server speaks version %d",
+			ErrProtocolVersion, d.FnHandshake.Version, ProtocolVersion)
+	}
+
+	// Degrade to JSON for clients that cannot decode binary frames.
+	// Server-Sent Events can only carry text.
+	c.encoding = JSONEncoding
+	if _, sse := c.transport.(*sseTransport); !sse && d.FnHandshake.has(FeatureBinary) {
+		c.encoding = BinaryEncoding
+	}
+
+	reply := newDispatch("fncmp-handshake")
+	reply.Function = handshake
+	reply.ConnID = c.ID
+	reply.HandlerID = c.HandlerID
+	reply.FnHandshake = FnHandshake{
+		Version:  ProtocolVersion,
+		Features: c.app.features(),
+		BuildID:  buildID,
+	}
+	b, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	c.enqueue("", websocket.TextMessage, b)
+	return nil
+}
+
+// reject tells the client why its connection is refused and closes it
+func (c *conn) reject(err error) {
+	d := newDispatch("fncmp-reject")
+	d.Function = _error
+	d.ConnID = c.ID
+	d.FnError.Message = err.Error()
+	if b, err := json.Marshal(d); err == nil {
+		c.enqueue("", websocket.TextMessage, b)
+	}
+	c.app.log.Warn("rejected client", c.logFields("error", err)...)
+	c.app.observe(func(h Hooks) { h.Error(errorKindHandshake) })
+	c.drain(websocket.ClosePolicyViolation, err.Error())
+}
+
+// closeReason truncates reason to fit in a close frame without splitting a rune
+func closeReason(reason string) string {
+	if len(reason) <= maxCloseReason {
+		return reason
+	}
+	n := maxCloseReason
+	for n > 0 && !utf8.RuneStart(reason[n]) {
+		n--
+	}
+	return reason[:n]
+}
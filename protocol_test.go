@@ -0,0 +1,109 @@
+package fncmp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// rejected returns the error sent to a client before its conn is closed
+func rejected(t *testing.T, tr *memTransport) string {
+	t.Helper()
+	var d Dispatch
+	select {
+	case msg := <-tr.out:
+		if err := json.Unmarshal(msg, &d); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("client not rejected")
+	}
+	if d.Function != _error {
+		t.Fatalf("dispatch = %s, want error", d.Function)
+	}
+	select {
+	case <-tr.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rejected conn not closed")
+	}
+	return d.FnError.Message
+}
+
+func TestHandshakeRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    error
+	}{
+		{
+			name:    "version mismatch",
+			message: `{"function":"handshake","handshake":{"version":2}}`,
+			want:    ErrProtocolVersion,
+		},
+		{
+			name:    "missing handshake",
+			message: `{"function":"event","event":{"id":"listener"}}`,
+			want:    ErrHandshakeRequired,
+		},
+		{
+			name:    "invalid json",
+			message: `handshake`,
+			want:    ErrHandshakeRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp(&Config{Silent: true})
+			h := newTestHandler(t, app)
+			tr := newMemTransport()
+			c := app.addConn(tr, httptest.NewRequest(http.MethodGet, "/", nil), h.id, "conn", clickCounter)
+			go c.listen()
+
+			tr.in <- []byte(tt.message)
+			if msg := rejected(t, tr); !strings.HasPrefix(msg, tt.want.Error()) {
+				t.Errorf("rejected with %q, want %q", msg, tt.want)
+			}
+			if _, ok := app.conns.Get("conn"); ok {
+				t.Error("rejected conn still registered")
+			}
+		})
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	app.handshakeTimeout = 10 * time.Millisecond
+	h := newTestHandler(t, app)
+	tr := newMemTransport()
+	c := app.addConn(tr, httptest.NewRequest(http.MethodGet, "/", nil), h.id, "conn", clickCounter)
+	go c.listen()
+
+	if msg := rejected(t, tr); msg != ErrHandshakeTimeout.Error() {
+		t.Errorf("rejected with %q, want %q", msg, ErrHandshakeTimeout)
+	}
+}
+
+func TestCloseReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+		want   int
+	}{
+		{name: "short", reason: "bye", want: 3},
+		{name: "limit", reason: strings.Repeat("a", maxCloseReason), want: maxCloseReason},
+		{name: "long", reason: strings.Repeat("a", 200), want: maxCloseReason},
+		{name: "multibyte", reason: strings.Repeat("é", 100), want: maxCloseReason - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := closeReason(tt.reason)
+			if len(got) != tt.want || !utf8.ValidString(got) {
+				t.Errorf("closeReason = %d bytes, want %d valid bytes", len(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package fncmp
+
+import (
+	"sync"
+)
+
+// defaultSendBuffer is the outbound queue size used when Config.SendBuffer is not set
+const defaultSendBuffer = 16
+
+// OverflowPolicy determines what happens when a connection's outbound queue is full
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest queued message to make room for the new one
+	DropOldest OverflowPolicy = iota
+	// CoalesceTarget replaces a queued render of the same target with the new one,
+	// falling back to DropOldest when there is nothing to coalesce
+	CoalesceTarget
+	// DisconnectSlow closes the connection of a client that cannot keep up
+	DisconnectSlow
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop_oldest"
+	case CoalesceTarget:
+		return "coalesce_target"
+	case DisconnectSlow:
+		return "disconnect_slow"
+	default:
+		return "unknown"
+	}
+}
+
+// outbound is a message waiting to be written to a connection
+type outbound struct {
+	// key identifies the render target of the message, empty if it cannot be coalesced
+	key     string
+	msgType int
+	msg     []byte
+}
+
+// sendQueue is a bounded outbound message queue owned by a single connection
+type sendQueue struct {
+	mu        sync.Mutex
+	items     []outbound
+	size      int
+	policy    OverflowPolicy
+	ready     chan struct{}
+	closed    bool
+	dropped   uint64
+	coalesced uint64
+	// onDrop is called when a message is dropped, with the queue locked
+	onDrop func()
+}
+
+func newSendQueue(size int, policy OverflowPolicy) *sendQueue {
+	if size <= 0 {
+		size = defaultSendBuffer
+	}
+	return &sendQueue{
+		items:  make([]outbound, 0, size),
+		size:   size,
+		policy: policy,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push adds a message to the queue without blocking, applying the overflow policy if full
+func (q *sendQueue) push(o outbound) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrConnectionClosed
+	}
+	if len(q.items) >= q.size {
+		switch q.policy {
+		case DisconnectSlow:
+			return ErrQueueOverflow
+		case CoalesceTarget:
+			if i := q.indexOf(o.key); i >= 0 {
+				q.items = append(q.items[:i], q.items[i+1:]...)
+				q.coalesced++
+				break
+			}
+			fallthrough
+		default:
+			q.items = q.items[1:]
+			q.dropped++
+			if q.onDrop != nil {
+				q.onDrop()
+			}
+		}
+	}
+	q.items = append(q.items, o)
+	q.signal()
+	return nil
+}
+
+// pop blocks until a message is available and returns it. Once the queue is
+// closed, remaining messages are still returned before pop reports false.
+func (q *sendQueue) pop() (outbound, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			o := q.items[0]
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			return o, true
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return outbound{}, false
+		}
+		q.mu.Unlock()
+		<-q.ready
+	}
+}
+
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.signal()
+}
+
+func (q *sendQueue) indexOf(key string) int {
+	if key == "" {
+		return -1
+	}
+	for i, o := range q.items {
+		if o.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// signal wakes a waiting pop, must be called with q.mu held
+func (q *sendQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *sendQueue) stats() (depth int, dropped uint64, coalesced uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items), q.dropped, q.coalesced
+}
+
+// QueueStats reports the state of a connection's outbound queue
+type QueueStats struct {
+	ConnID    string
+	HandlerID string
+	Depth     int
+	Capacity  int
+	Policy    OverflowPolicy
+	Dropped   uint64
+	Coalesced uint64
+}
+
+// Queues returns the outbound queue stats of every open connection
+func (a *App) Queues() []QueueStats {
+	all := a.conns.All()
+	stats := make([]QueueStats, 0, len(all))
+	for _, c := range all {
+		depth, dropped, coalesced := c.queue.stats()
+		stats = append(stats, QueueStats{
+			ConnID:    c.ID,
+			HandlerID: c.HandlerID,
+			Depth:     depth,
+			Capacity:  c.queue.size,
+			Policy:    c.queue.policy,
+			Dropped:   dropped,
+			Coalesced: coalesced,
+		})
+	}
+	return stats
+}
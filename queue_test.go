@@ -0,0 +1,92 @@
+package fncmp
+
+import (
+	"testing"
+)
+
+func TestSendQueueOverflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  OverflowPolicy
+		push    []string
+		want    []string
+		err     error
+		dropped uint64
+	}{
+		{
+			name:   "fits",
+			policy: DropOldest,
+			push:   []string{"a", "b"},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:    "drop oldest",
+			policy:  DropOldest,
+			push:    []string{"a", "b", "c"},
+			want:    []string{"b", "c"},
+			dropped: 1,
+		},
+		{
+			name:   "coalesce target",
+			policy: CoalesceTarget,
+			push:   []string{"a", "b", "a"},
+			want:   []string{"b", "a"},
+		},
+		{
+			name:    "coalesce falls back to drop oldest",
+			policy:  CoalesceTarget,
+			push:    []string{"a", "b", "c"},
+			want:    []string{"b", "c"},
+			dropped: 1,
+		},
+		{
+			name:   "disconnect slow",
+			policy: DisconnectSlow,
+			push:   []string{"a", "b", "c"},
+			want:   []string{"a", "b"},
+			err:    ErrQueueOverflow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newSendQueue(2, tt.policy)
+			var err error
+			for _, key := range tt.push {
+				if e := q.push(outbound{key: key, msg: []byte(key)}); e != nil {
+					err = e
+				}
+			}
+			if err != tt.err {
+				t.Fatalf("push error = %v, want %v", err, tt.err)
+			}
+			q.close()
+			var got []string
+			for {
+				o, ok := q.pop()
+				if !ok {
+					break
+				}
+				got = append(got, string(o.msg))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("popped %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("popped %q, want %q", got, tt.want)
+				}
+			}
+			if _, dropped, _ := q.stats(); dropped != tt.dropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestSendQueueClosed(t *testing.T) {
+	q := newSendQueue(1, DropOldest)
+	q.close()
+	if err := q.push(outbound{msg: []byte("a")}); err != ErrConnectionClosed {
+		t.Fatalf("push after close = %v, want %v", err, ErrConnectionClosed)
+	}
+}
@@ -0,0 +1,124 @@
+package fncmp
+
+import (
+	"time"
+)
+
+// LimitPolicy determines what happens when a client exceeds a rate limit
+type LimitPolicy int
+
+const (
+	// LimitDrop discards messages over the limit
+	LimitDrop LimitPolicy = iota
+	// LimitThrottle delays reading the client's next message until the limit allows it
+	LimitThrottle
+	// LimitDisconnect closes the connection of a client over the limit
+	LimitDisconnect
+)
+
+func (p LimitPolicy) String() string {
+	switch p {
+	case LimitDrop:
+		return "drop"
+	case LimitThrottle:
+		return "throttle"
+	case LimitDisconnect:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// Rate allows Events messages Per duration, with bursts of up to Events messages.
+//
+// The zero Rate is unlimited.
+type Rate struct {
+	Events int
+	Per    time.Duration
+}
+
+func (r Rate) unlimited() bool {
+	return r.Events <= 0 || r.Per <= 0
+}
+
+// valid reports whether r is unlimited or has both Events and Per set
+func (r Rate) valid() bool {
+	return r == Rate{} || r.Events > 0 && r.Per > 0
+}
+
+// RateLimit limits the inbound messages of each connection
+type RateLimit struct {
+	// Conn limits all messages received from a connection
+	Conn Rate
+	// Listener limits the events received for each EventListener of a connection
+	Listener Rate
+	// Policy determines what happens when a limit is exceeded
+	Policy LimitPolicy
+}
+
+// limiter is a token bucket enforcing a Rate. It is not safe for concurrent use.
+type limiter struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(r Rate) *limiter {
+	return &limiter{
+		rate:   r,
+		tokens: float64(r.Events),
+		last:   time.Now(),
+	}
+}
+
+// allow reports whether a message may pass now, consuming a token if so.
+// Otherwise it returns how long until a token is available.
+func (l *limiter) allow() (bool, time.Duration) {
+	if l == nil || l.rate.unlimited() {
+		return true, 0
+	}
+	now := time.Now()
+	// Per may be shorter than Events nanoseconds, so a token's interval is fractional
+	perToken := float64(l.rate.Per) / float64(l.rate.Events)
+	l.tokens += float64(now.Sub(l.last)) / perToken
+	if max := float64(l.rate.Events); l.tokens > max {
+		l.tokens = max
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - l.tokens) * perToken)
+}
+
+// limits enforces an App's RateLimit on a single conn's reader
+type limits struct {
+	config    RateLimit
+	conn      *limiter
+	listeners map[string]*limiter
+}
+
+func newLimits(config RateLimit) *limits {
+	l := &limits{
+		config:    config,
+		listeners: make(map[string]*limiter),
+	}
+	if !config.Conn.unlimited() {
+		l.conn = newLimiter(config.Conn)
+	}
+	return l
+}
+
+// listener returns the limiter of an EventListener, nil if listeners are unlimited
+func (l *limits) listener(id string) *limiter {
+	if l.config.Listener.unlimited() {
+		return nil
+	}
+	lim, ok := l.listeners[id]
+	if !ok {
+		lim = newLimiter(l.config.Listener)
+		l.listeners[id] = lim
+	}
+	return lim
+}
@@ -0,0 +1,124 @@
+package fncmp
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(Rate{Events: 2, Per: time.Second})
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow(); !ok {
+			t.Fatalf("message %d of the burst denied", i)
+		}
+	}
+	ok, wait := l.allow()
+	if ok {
+		t.Fatal("message over the burst allowed")
+	}
+	if wait <= 0 || wait > 500*time.Millisecond {
+		t.Errorf("wait = %v, want up to one token's interval", wait)
+	}
+
+	// A token is refilled every Per/Events
+	l.last = l.last.Add(-500 * time.Millisecond)
+	if ok, _ := l.allow(); !ok {
+		t.Error("message denied after a token was refilled")
+	}
+	if ok, _ := l.allow(); ok {
+		t.Error("message allowed with the bucket empty")
+	}
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	for _, r := range []Rate{{}, {Events: 1}, {Per: time.Second}} {
+		l := newLimiter(r)
+		for i := 0; i < 100; i++ {
+			if ok, _ := l.allow(); !ok {
+				t.Fatalf("%+v denied message %d", r, i)
+			}
+		}
+	}
+	var l *limiter
+	if ok, _ := l.allow(); !ok {
+		t.Error("nil limiter denied a message")
+	}
+}
+
+func TestLimiterFractionalInterval(t *testing.T) {
+	// A token every 0.01ns
+	l := newLimiter(Rate{Events: 1000, Per: 10 * time.Nanosecond})
+	for i := 0; i < 1000; i++ {
+		l.allow()
+	}
+	l.last = l.last.Add(-time.Nanosecond)
+	if ok, wait := l.allow(); !ok || wait != 0 {
+		t.Errorf("allow() = %v, %v after a refill", ok, wait)
+	}
+	if math.IsNaN(l.tokens) || l.tokens < 0 {
+		t.Errorf("tokens = %v", l.tokens)
+	}
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		rate Rate
+		want Rate
+	}{
+		{name: "unlimited", rate: Rate{}, want: Rate{}},
+		{name: "valid", rate: Rate{Events: 5, Per: time.Second}, want: Rate{Events: 5, Per: time.Second}},
+		{name: "no per", rate: Rate{Events: 5}, want: Rate{}},
+		{name: "no events", rate: Rate{Per: time.Second}, want: Rate{}},
+		{name: "negative", rate: Rate{Events: -1, Per: time.Second}, want: Rate{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp(&Config{Silent: true, RateLimit: RateLimit{Conn: tt.rate, Listener: tt.rate}})
+			if got := app.Config().RateLimit; got.Conn != tt.want || got.Listener != tt.want {
+				t.Errorf("RateLimit = %+v, want both %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func clickCounter(ctx context.Context) FnComponent {
+	return NewFn(ctx, HTML("button")).WithEvents(func(ctx context.Context) FnComponent {
+		return NewFn(ctx, HTML("clicked"))
+	}, OnClick)
+}
+
+func TestListenerRateLimitDrop(t *testing.T) {
+	app := NewApp(&Config{Silent: true, RateLimit: RateLimit{
+		Listener: Rate{Events: 2, Per: time.Minute},
+		Policy:   LimitDrop,
+	}})
+	tc := newTestClient(t, newTestHandler(t, app), clickCounter)
+	tc.nextRender()
+	el := tc.listener(OnClick)
+	for i := 0; i < 3; i++ {
+		tc.fire(el)
+	}
+	tc.nextRender()
+	tc.nextRender()
+	tc.quiet(100 * time.Millisecond)
+}
+
+func TestConnRateLimitDisconnect(t *testing.T) {
+	app := NewApp(&Config{Silent: true, RateLimit: RateLimit{
+		Conn:   Rate{Events: 1, Per: time.Minute},
+		Policy: LimitDisconnect,
+	}})
+	tc := newTestClient(t, newTestHandler(t, app), clickCounter)
+	tc.nextRender()
+	el := tc.listener(OnClick)
+	tc.fire(el)
+	tc.fire(el)
+	select {
+	case <-tc.conn.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("conn over the limit was not closed")
+	}
+}
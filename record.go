@@ -0,0 +1,88 @@
+package fncmp
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// RecordedDispatch is a dispatch read from ("in") or sent to ("out") a client
+type RecordedDispatch struct {
+	Time      time.Time `json:"time"`
+	ConnID    string    `json:"conn_id"`
+	Direction string    `json:"direction"`
+	Dispatch  Dispatch  `json:"dispatch"`
+}
+
+// Recorder receives every dispatch exchanged with clients, see Config.Recorder.
+//
+// Recordings contain user input, such as event and form data, and the HTML
+// rendered for each user. Set Config.Redact to remove sensitive data before it
+// is recorded.
+//
+// Record is called from the connection and handler goroutines, so
+// implementations must be safe for concurrent use.
+type Recorder interface {
+	Record(d RecordedDispatch) error
+}
+
+// JSONLRecorder writes recorded dispatches as JSON lines, the format read by Replay
+type JSONLRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+// NewJSONLRecorder returns a Recorder writing to w
+func NewJSONLRecorder(w io.Writer) *JSONLRecorder {
+	return &JSONLRecorder{enc: json.NewEncoder(w)}
+}
+
+// NewFileRecorder returns a Recorder appending to the file at path, which is
+// created readable only by its owner if it does not exist
+func NewFileRecorder(path string) (*JSONLRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	r := NewJSONLRecorder(f)
+	r.c = f
+	return r, nil
+}
+
+func (r *JSONLRecorder) Record(d RecordedDispatch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(d)
+}
+
+// Close closes the file of a Recorder created by NewFileRecorder
+func (r *JSONLRecorder) Close() error {
+	if r.c == nil {
+		return nil
+	}
+	return r.c.Close()
+}
+
+// record passes a dispatch read from ("in") or sent to ("out") the client to Config.Recorder
+func (c *conn) record(direction string, d *Dispatch) {
+	recorder := c.app.config.Recorder
+	if recorder == nil {
+		return
+	}
+	rd := RecordedDispatch{
+		Time:      time.Now(),
+		ConnID:    c.ID,
+		Direction: direction,
+		Dispatch:  *d,
+	}
+	if redact := c.app.config.Redact; redact != nil {
+		redact(&rd)
+	}
+	err := recorder.Record(rd)
+	if err != nil {
+		c.app.log.Warn("error recording dispatch", c.logFields("error", err)...)
+	}
+}
@@ -0,0 +1,75 @@
+package fncmp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordRedact(t *testing.T) {
+	var rec syncBuffer
+	app := NewApp(&Config{
+		Silent:   true,
+		Recorder: NewJSONLRecorder(&rec),
+		Redact: func(d *RecordedDispatch) {
+			if d.Dispatch.Function == event {
+				d.Dispatch.FnEvent.Data = "redacted"
+			}
+		},
+	})
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		return NewFn(ctx, HTML("form")).WithEvents(func(ctx context.Context) FnComponent {
+			data, _ := UnmarshalEventData[map[string]string](ctx.Value(EventKey).(EventListener))
+			if data["password"] != "hunter2" {
+				return NewFn(ctx, HTML("redacted before handling"))
+			}
+			return NewFn(ctx, HTML("handled"))
+		}, OnSubmit)
+	})
+	tc.nextRender()
+	el := tc.listener(OnSubmit)
+	el.Data = map[string]string{"password": "hunter2"}
+	tc.fire(el)
+	if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "handled") {
+		t.Fatalf("render = %q", d.FnRender.HTML)
+	}
+	tc.conn.close()
+	<-tc.conn.done
+	time.Sleep(50 * time.Millisecond)
+
+	recording := rec.String()
+	if strings.Contains(recording, "hunter2") {
+		t.Errorf("recording contains redacted data:\n%s", recording)
+	}
+	if !strings.Contains(recording, `"data":"redacted"`) {
+		t.Errorf("recording missing the redacted event:\n%s", recording)
+	}
+}
+
+func TestNewFileRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	r, err := NewFileRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Record(RecordedDispatch{ConnID: "conn", Direction: "in"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("recording permissions = %o, want 600", perm)
+	}
+	b, _ := os.ReadFile(path)
+	if !strings.Contains(string(b), `"conn_id":"conn"`) {
+		t.Errorf("recording = %s", b)
+	}
+}
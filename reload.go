@@ -0,0 +1,91 @@
+package fncmp
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// watchInterval is how often WatchFiles polls for changes
+const watchInterval = 500 * time.Millisecond
+
+// Reload re-renders the HandleFn of every connection of the default App
+func Reload() {
+	defaultApp.Reload()
+}
+
+// WatchFiles watches files for the default App
+func WatchFiles(ctx context.Context, paths ...string) error {
+	return defaultApp.WatchFiles(ctx, paths...)
+}
+
+// Reload re-renders the HandleFn of every open connection, replacing the page's MainTag
+func (a *App) Reload() {
+	for _, c := range a.conns.All() {
+		if c.accepted.Load() {
+			c.queueRoot()
+		}
+	}
+}
+
+// WatchFiles calls Reload whenever a file under paths is created, changed or
+// removed, until ctx is done. It requires DevMode.
+//
+// Only code read at runtime can be reloaded, such as the text files templ
+// generates in watch mode:
+//
+//	go fncmp.WatchFiles(ctx, "views")
+//
+// Changes to Go code require a restart, after which DevMode clients reload
+// the page by themselves.
+func (a *App) WatchFiles(ctx context.Context, paths ...string) error {
+	if !a.config.DevMode {
+		return ErrDevModeRequired
+	}
+	last := snapshot(paths)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		current := snapshot(paths)
+		if changed(last, current) {
+			a.log.Info("files changed, reloading", "connections", len(a.conns.All()))
+			a.Reload()
+		}
+		last = current
+	}
+}
+
+// snapshot returns the modification times of the files under paths
+func snapshot(paths []string) map[string]time.Time {
+	files := make(map[string]time.Time)
+	for _, root := range paths {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				files[path] = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+func changed(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	for path, t := range a {
+		if u, ok := b[path]; !ok || !t.Equal(u) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package fncmp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconnectSameID(t *testing.T) {
+	app := NewApp(&Config{Silent: true, DevMode: true})
+	h := newTestHandler(t, app)
+	connect := func() *testClient {
+		tr := newMemTransport()
+		c := app.addConn(tr, httptest.NewRequest(http.MethodGet, "/", nil), h.id, "conn", clickCounter)
+		tc := connectTestClient(t, c, tr)
+		tc.nextRender()
+		return tc
+	}
+	old := connect()
+	// The client reconnects before its old conn notices it is gone
+	tc := connect()
+	old.conn.close()
+
+	if c, ok := app.conns.Get("conn"); !ok || c != tc.conn {
+		t.Fatal("closing the old conn removed the new one")
+	}
+	tc.fire(tc.listener(OnClick))
+	if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "clicked") {
+		t.Errorf("render = %q, want clicked", d.FnRender.HTML)
+	}
+
+	tc.conn.close()
+	if _, ok := app.conns.Get("conn"); ok {
+		t.Error("closed conn still registered")
+	}
+}
+
+func TestReload(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	renders := 0
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		renders++
+		return NewFn(ctx, HTML("root"))
+	})
+	tc.nextRender()
+
+	app.Reload()
+	if d := tc.nextRender(); d.FnRender.Tag != MainTag || !strings.Contains(d.FnRender.HTML, "root") {
+		t.Errorf("reload render = %+v", d.FnRender)
+	}
+	if renders != 2 {
+		t.Errorf("root rendered %d times, want 2", renders)
+	}
+}
+
+func TestWatchFilesRequiresDevMode(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	if err := app.WatchFiles(context.Background(), t.TempDir()); err != ErrDevModeRequired {
+		t.Errorf("WatchFiles = %v, want %v", err, ErrDevModeRequired)
+	}
+}
+
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "view.txt")
+	if err := os.WriteFile(file, []byte("v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	app := NewApp(&Config{Silent: true, DevMode: true})
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		b, _ := os.ReadFile(file)
+		return NewFn(ctx, HTML(b))
+	})
+	tc.nextRender()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan error)
+	go func() { watched <- app.WatchFiles(ctx, dir) }()
+	// Let WatchFiles take its first snapshot
+	time.Sleep(watchInterval / 2)
+	if err := os.WriteFile(file, []byte("v2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(file, later, later)
+
+	if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "v2") {
+		t.Errorf("reload render = %q, want v2", d.FnRender.HTML)
+	}
+	cancel()
+	if err := <-watched; err != context.Canceled {
+		t.Errorf("WatchFiles = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,279 @@
+package fncmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// replayIdle is how long Replay waits for the next dispatch before assuming there is none
+	replayIdle = 500 * time.Millisecond
+	// replayBuffer is large enough that no replayed dispatch is dropped from the queue
+	replayBuffer = 1024
+)
+
+// ReplayResult holds the dispatches sent to the client in a recording and in its replay.
+//
+// Both are normalized so equal output compares equal: IDs are numbered in
+// order of appearance, and signatures and trace IDs are removed.
+type ReplayResult struct {
+	Recorded []json.RawMessage
+	Replayed []json.RawMessage
+}
+
+// Diff returns an error describing the first difference between the recorded
+// and replayed dispatches, or nil if the replay reproduced the recording
+func (r *ReplayResult) Diff() error {
+	for i := 0; i < len(r.Recorded) && i < len(r.Replayed); i++ {
+		if !bytes.Equal(r.Recorded[i], r.Replayed[i]) {
+			return fmt.Errorf("dispatch %d differs\nrecorded: %s\nreplayed: %s", i, r.Recorded[i], r.Replayed[i])
+		}
+	}
+	if len(r.Recorded) != len(r.Replayed) {
+		return fmt.Errorf("recorded %d dispatches, replayed %d", len(r.Recorded), len(r.Replayed))
+	}
+	return nil
+}
+
+// Replay feeds the dispatches a client sent in a recording made with
+// NewJSONLRecorder back through hf, to reproduce a session in tests:
+//
+//	f, _ := os.Open("testdata/session.jsonl")
+//	result, err := fncmp.Replay(ctx, f, page)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	if err := result.Diff(); err != nil {
+//		t.Error(err)
+//	}
+//
+// Only the first connection in the recording is replayed, on an App of its
+// own. Each inbound dispatch is sent once the replay has sent the client as
+// many dispatches as preceded it in the recording.
+func Replay(ctx context.Context, recording io.Reader, hf HandleFn) (*ReplayResult, error) {
+	records, err := readRecording(recording)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReplayResult{}
+	if len(records) == 0 {
+		return result, nil
+	}
+	connID := records[0].ConnID
+
+	app := NewApp(&Config{Silent: true, SendBuffer: replayBuffer})
+	h := app.newHandler()
+	h.listen()
+	defer h.stop()
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	t := newMemTransport()
+	c := app.addConn(t, r, h.id, connID, hf)
+	go c.listen()
+	defer c.close()
+
+	recorded := newIDNormalizer()
+	replayed := newIDNormalizer()
+
+	// await collects replayed dispatches until there are n, or none is sent for replayIdle
+	await := func(n int) error {
+		for len(result.Replayed) < n {
+			select {
+			case msg := <-t.out:
+				var d Dispatch
+				if err := json.Unmarshal(msg, &d); err != nil {
+					return err
+				}
+				if d.Function == handshake {
+					continue
+				}
+				result.Replayed = append(result.Replayed, replayed.normalize(&d))
+			case <-time.After(replayIdle):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+	send := func(d Dispatch) error {
+		msg, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		select {
+		case t.in <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	hello := newDispatch("fncmp-handshake")
+	hello.Function = handshake
+	hello.FnHandshake.Version = ProtocolVersion
+	if err := send(*hello); err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if rec.ConnID != connID {
+			continue
+		}
+		switch rec.Direction {
+		case "out":
+			result.Recorded = append(result.Recorded, recorded.normalize(&rec.Dispatch))
+		case "in":
+			if err := await(len(result.Recorded)); err != nil {
+				return nil, err
+			}
+			// Map the recording's IDs to the ones generated by the replay
+			var d Dispatch
+			if err := json.Unmarshal(replayed.denormalize(recorded.normalize(&rec.Dispatch)), &d); err != nil {
+				return nil, err
+			}
+			d.ConnID = c.ID
+			d.HandlerID = h.id
+			if d.Function == event {
+				d.FnEvent.Signature = app.sign(c.ID, h.id, d.FnEvent.ID)
+			}
+			if err := send(d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Collect the rest of the output, including any the recording does not have
+	if err := await(math.MaxInt); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func readRecording(r io.Reader) ([]RecordedDispatch, error) {
+	var records []RecordedDispatch
+	dec := json.NewDecoder(r)
+	for {
+		var rec RecordedDispatch
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid recording: %w", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+var (
+	uuidPattern        = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	placeholderPattern = regexp.MustCompile(`<id-(\d+)>`)
+	// signatureAttr matches listener signatures in the escaped events attribute of rendered HTML
+	signatureAttr = regexp.MustCompile(`(&#34;signature&#34;:&#34;)[A-Za-z0-9_-]*`)
+)
+
+// idNormalizer numbers the random IDs of a dispatch stream in order of appearance
+type idNormalizer struct {
+	ids []string
+	seq map[string]int
+}
+
+func newIDNormalizer() *idNormalizer {
+	return &idNormalizer{seq: make(map[string]int)}
+}
+
+// normalize encodes a dispatch with its IDs replaced by placeholders, and its
+// signatures and trace ID removed
+func (n *idNormalizer) normalize(d *Dispatch) json.RawMessage {
+	c := *d
+	c.TraceID = ""
+	c.FnEvent.Signature = ""
+	c.FnRender.EventListeners = make([]EventListener, len(d.FnRender.EventListeners))
+	for i, el := range d.FnRender.EventListeners {
+		el.Signature = ""
+		c.FnRender.EventListeners[i] = el
+	}
+	if d.FnRender.EventListeners == nil {
+		c.FnRender.EventListeners = nil
+	}
+	c.FnRender.HTML = signatureAttr.ReplaceAllString(c.FnRender.HTML, "${1}")
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil
+	}
+	return uuidPattern.ReplaceAllFunc(b, func(id []byte) []byte {
+		i, ok := n.seq[string(id)]
+		if !ok {
+			i = len(n.ids)
+			n.seq[string(id)] = i
+			n.ids = append(n.ids, string(id))
+		}
+		return []byte("<id-" + strconv.Itoa(i) + ">")
+	})
+}
+
+// denormalize replaces placeholders with the IDs they stand for in n's stream
+func (n *idNormalizer) denormalize(b []byte) []byte {
+	return placeholderPattern.ReplaceAllFunc(b, func(p []byte) []byte {
+		i, _ := strconv.Atoi(string(placeholderPattern.FindSubmatch(p)[1]))
+		if i < len(n.ids) {
+			return []byte(n.ids[i])
+		}
+		return p
+	})
+}
+
+// memTransport is the in-memory transport Replay drives a conn with
+type memTransport struct {
+	in   chan []byte
+	out  chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+func newMemTransport() *memTransport {
+	return &memTransport{
+		in:   make(chan []byte),
+		out:  make(chan []byte),
+		done: make(chan struct{}),
+	}
+}
+
+func (t *memTransport) ReadMessage() (int, []byte, error) {
+	select {
+	case msg := <-t.in:
+		return websocket.TextMessage, msg, nil
+	case <-t.done:
+		return 0, nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+}
+
+func (t *memTransport) WriteMessage(messageType int, data []byte) error {
+	select {
+	case t.out <- data:
+		return nil
+	case <-t.done:
+		return ErrConnectionClosed
+	}
+}
+
+func (t *memTransport) SetReadLimit(limit int64) {}
+
+func (t *memTransport) Close() error {
+	t.once.Do(func() {
+		close(t.done)
+	})
+	return nil
+}
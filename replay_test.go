@@ -0,0 +1,98 @@
+package fncmp
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// counter renders a button counting its clicks, starting at start
+func counter(start int) HandleFn {
+	return func(ctx context.Context) FnComponent {
+		n := start
+		var button func(ctx context.Context) FnComponent
+		button = func(ctx context.Context) FnComponent {
+			return NewFn(ctx, Text(strconv.Itoa(n))).WithEvents(func(ctx context.Context) FnComponent {
+				n++
+				return button(ctx).SwapTagInner(MainTag)
+			}, OnClick)
+		}
+		return button(ctx)
+	}
+}
+
+// record records a session of clicks on hf
+func record(t *testing.T, hf HandleFn, clicks int) string {
+	var rec syncBuffer
+	app := NewApp(&Config{Silent: true, Recorder: NewJSONLRecorder(&rec)})
+	tc := newTestClient(t, newTestHandler(t, app), hf)
+	d := tc.nextRender()
+	for i := 0; i < clicks; i++ {
+		// Each render replaces the button and its listener
+		tc.fire(d.FnRender.EventListeners[0])
+		d = tc.nextRender()
+	}
+	tc.conn.close()
+	<-tc.conn.done
+	// Outbound dispatches are recorded once queued
+	time.Sleep(50 * time.Millisecond)
+	return rec.String()
+}
+
+func TestReplay(t *testing.T) {
+	recording := record(t, counter(0), 3)
+	if n := strings.Count(recording, "\n"); n != 7 {
+		t.Fatalf("recorded %d dispatches, want 7:\n%s", n, recording)
+	}
+
+	result, err := Replay(context.Background(), strings.NewReader(recording), counter(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Recorded) != 4 {
+		t.Errorf("recorded %d renders, want 4", len(result.Recorded))
+	}
+	if err := result.Diff(); err != nil {
+		t.Errorf("replay of the same HandleFn differs: %v", err)
+	}
+
+	result, err = Replay(context.Background(), strings.NewReader(recording), counter(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.Diff(); err == nil {
+		t.Error("replay of a changed HandleFn does not differ")
+	}
+}
+
+func TestReplayInvalidRecording(t *testing.T) {
+	if _, err := Replay(context.Background(), strings.NewReader("{"), counter(0)); err == nil {
+		t.Error("invalid recording replayed")
+	}
+	result, err := Replay(context.Background(), strings.NewReader(""), counter(0))
+	if err != nil || result.Diff() != nil {
+		t.Errorf("empty recording: %v, %v", err, result.Diff())
+	}
+}
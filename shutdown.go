@@ -0,0 +1,108 @@
+package fncmp
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeTimeout bounds how long writing a close frame may block
+const closeTimeout = time.Second
+
+// Shutdown gracefully stops the App.
+//
+// New connections are rejected, handlers finish the event in flight and flush
+// queued components, and every client is sent the optional notice component
+// rendered into its MainTag before its outbound queue is drained and the
+// connection is closed with a close frame. If ctx expires first, remaining
+// connections are closed immediately and ctx's error is returned.
+func (a *App) Shutdown(ctx context.Context, notice Component) error {
+	if !a.shuttingDown.CompareAndSwap(false, true) {
+		return ErrShuttingDown
+	}
+
+	for _, h := range a.handlers.All() {
+		h.stop()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		a.closeAll()
+		return ctx.Err()
+	}
+
+	conns := a.conns.All()
+	// Workers finish the event in flight once their handler is stopped
+	for _, c := range conns {
+		select {
+		case <-c.worked:
+		case <-ctx.Done():
+			a.closeAll()
+			return ctx.Err()
+		}
+	}
+	for _, c := range conns {
+		if notice != nil {
+			c.notify(notice)
+		}
+		// The conn's writer flushes what is left, sends a close frame and exits
+		c.drain(websocket.CloseGoingAway, ErrShuttingDown.Error())
+	}
+	for _, c := range conns {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			a.closeAll()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (a *App) closeAll() {
+	for _, c := range a.conns.All() {
+		c.close()
+	}
+}
+
+// notify renders a component into the client's MainTag, bypassing the stopped handler
+func (c *conn) notify(notice Component) {
+	c.pushRender("fncmp-shutdown", MainTag, "", RenderComponent(notice))
+}
+
+// pushRender swaps the inner HTML of the client's tag or element with the
+// given id, bypassing the conn's handler queue
+func (c *conn) pushRender(key string, tag Tag, targetID string, html string) {
+	h, ok := c.app.handlers.Get(c.HandlerID)
+	if !ok {
+		return
+	}
+	d := newDispatch(key)
+	d.conn = c
+	d.ConnID = c.ID
+	d.HandlerID = c.HandlerID
+	d.Function = render
+	d.FnRender.Tag = tag
+	d.FnRender.TargetID = targetID
+	d.FnRender.Inner = true
+	d.FnRender.HTML = c.app.minify(html)
+	h.MarshalAndPublish(context.Background(), *d)
+}
+
+// writeClose tells the client the connection is closing on purpose
+func (c *conn) writeClose(code int, reason string) {
+	switch t := c.transport.(type) {
+	case *websocket.Conn:
+		msg := websocket.FormatCloseMessage(code, closeReason(reason))
+		t.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+	case *sseTransport:
+		// EventSource reconnects when a stream ends unless told to close
+		t.write([]byte("event: close\ndata: " + reason + "\n\n"))
+	}
+}
@@ -0,0 +1,105 @@
+package fncmp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownRejectsNewConns(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	page := func(w http.ResponseWriter, r *http.Request) {}
+	srv := httptest.NewServer(app.MiddleWareFn(page, clickCounter))
+	defer srv.Close()
+
+	if err := app.Shutdown(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Shutdown(context.Background(), nil); err != ErrShuttingDown {
+		t.Errorf("second Shutdown = %v, want %v", err, ErrShuttingDown)
+	}
+	res, err := http.Get(srv.URL + "/?fncmp_id=conn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("new conn status = %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestShutdownFinishesEvents(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		return NewFn(ctx, HTML("button")).WithEvents(func(ctx context.Context) FnComponent {
+			close(started)
+			<-release
+			return NewFn(ctx, HTML("clicked"))
+		}, OnClick)
+	})
+	tc.nextRender()
+	tc.fire(tc.listener(OnClick))
+	<-started
+
+	shutdown := make(chan error)
+	go func() { shutdown <- app.Shutdown(context.Background(), HTML("bye")) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v with an event in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	// The response and the notice are flushed before the conn closes
+	for _, want := range []string{"clicked", "bye"} {
+		if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, want) {
+			t.Fatalf("render = %q, want %q", d.FnRender.HTML, want)
+		}
+	}
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+	select {
+	case <-tc.tr.done:
+	default:
+		t.Error("transport not closed")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		return NewFn(ctx, HTML("button")).WithEvents(func(ctx context.Context) FnComponent {
+			close(started)
+			<-release
+			return NewFn(ctx, HTML("clicked"))
+		}, OnClick)
+	})
+	tc.nextRender()
+	tc.fire(tc.listener(OnClick))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := app.Shutdown(ctx, nil); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown = %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-tc.tr.done:
+	case <-time.After(time.Second):
+		t.Error("conn not closed after the deadline")
+	}
+}
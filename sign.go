@@ -0,0 +1,39 @@
+package fncmp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// newSecret generates a random secret for signing event listeners
+func newSecret() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("fncmp: failed to generate secret: " + err.Error())
+	}
+	return b
+}
+
+// sign returns the signature of an EventListener rendered for a conn and handler
+func (a *App) sign(connID, handlerID, listenerID string) string {
+	mac := hmac.New(sha256.New, a.config.Secret)
+	for _, s := range []string{connID, handlerID, listenerID} {
+		mac.Write([]byte(s))
+		// Separate fields so they cannot be shifted into one another
+		mac.Write([]byte{0})
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether an inbound event was signed by the App for the conn
+// it was received on, so events cannot be forged or replayed on other handlers
+// or connections
+func (c *conn) verify(d Dispatch) bool {
+	if d.HandlerID != c.HandlerID {
+		return false
+	}
+	expected := c.app.sign(c.ID, d.HandlerID, d.FnEvent.ID)
+	return hmac.Equal([]byte(expected), []byte(d.FnEvent.Signature))
+}
@@ -0,0 +1,56 @@
+package fncmp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	app := NewApp(&Config{Silent: true, Secret: []byte("secret")})
+	c := app.makeConn(httptest.NewRequest(http.MethodGet, "/", nil), "handler", "conn", nil)
+	signed := func(connID, handlerID, listenerID string) Dispatch {
+		d := Dispatch{HandlerID: handlerID}
+		d.FnEvent.ID = listenerID
+		d.FnEvent.Signature = app.sign(connID, handlerID, listenerID)
+		return d
+	}
+	tests := []struct {
+		name string
+		d    Dispatch
+		want bool
+	}{
+		{"valid", signed("conn", "handler", "listener"), true},
+		{"other conn", signed("other", "handler", "listener"), false},
+		{"other handler", signed("conn", "other", "listener"), false},
+		{"other listener", func() Dispatch {
+			d := signed("conn", "handler", "listener")
+			d.FnEvent.ID = "other"
+			return d
+		}(), false},
+		{"shifted fields", signed("con", "nhandler", "listener"), false},
+		{"unsigned", Dispatch{HandlerID: "handler", FnEvent: EventListener{ID: "listener"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.verify(tt.d); got != tt.want {
+				t.Errorf("verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForgedEventIgnored(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	tc := newTestClient(t, newTestHandler(t, app), clickCounter)
+	tc.nextRender()
+
+	d := newDispatch("fncmp-event")
+	d.Function = event
+	d.HandlerID = tc.conn.HandlerID
+	d.FnEvent = tc.listener(OnClick)
+	d.FnEvent.Signature = "forged"
+	tc.send(*d)
+	tc.quiet(100 * time.Millisecond)
+}
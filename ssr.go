@@ -0,0 +1,112 @@
+package fncmp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ssrAttr is set on the MainTag of a server rendered page to the ID of its pending conn
+	ssrAttr = "data-fncmp-conn"
+	// ssrTimeout is how long the conn of a server rendered page waits for its client
+	ssrTimeout = 30 * time.Second
+	// defaultMaxPending is used when Config.MaxPending is not set
+	defaultMaxPending = 1024
+)
+
+// serverRender renders hf into the MainTag of the page, for a conn the client
+// claims when it connects. The page is returned as is if it has no MainTag or
+// hf does not render into it.
+func (a *App) serverRender(page []byte, r *http.Request, h *handler, hf HandleFn) []byte {
+	start, open, end := mainTagAt(string(page))
+	if start < 0 {
+		return page
+	}
+	max := a.config.MaxPending
+	if max <= 0 {
+		max = defaultMaxPending
+	}
+	if a.pending.Len() >= max {
+		a.log.Warn("too many server rendered pages waiting for their client, skipping SSR", "pending", max)
+		return page
+	}
+
+	c := a.makeConn(r, h.id, uuid.New().String(), hf)
+	fn := c.rootFn()
+	if fn.dispatch.Function != render || fn.dispatch.FnRender.Tag != MainTag || !fn.dispatch.FnRender.Inner {
+		c.discard()
+		return page
+	}
+	var w Writer
+	fn.Render(fn.Context, &w)
+	html := a.minify(string(w.buf))
+
+	c.hydrate = true
+	if !a.pending.add(c, max) {
+		c.discard()
+		return page
+	}
+	time.AfterFunc(ssrTimeout, func() { a.expire(c) })
+
+	out := make([]byte, 0, len(page)+len(html)+len(c.ID)+len(ssrAttr)+4)
+	out = append(out, page[:start+len("<main")]...)
+	out = append(out, attr(ssrAttr, c.ID)...)
+	out = append(out, page[start+len("<main"):open]...)
+	out = append(out, html...)
+	out = append(out, page[end:]...)
+	return out
+}
+
+// expire discards a pending conn if its client has not claimed it
+func (a *App) expire(c *conn) {
+	if _, ok := a.claim(c.ID, c.HandlerID); ok {
+		c.discard()
+	}
+}
+
+// discard releases a conn that was never attached to a client, stopping the
+// goroutines its HandleFn started and dropping what they render
+func (c *conn) discard() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.app.listeners.Delete(c)
+		c.queue.close()
+		close(c.done)
+	})
+}
+
+// claim removes and returns the pending conn with the given IDs
+func (a *App) claim(ID string, handlerID string) (*conn, bool) {
+	a.pending.mu.Lock()
+	defer a.pending.mu.Unlock()
+	c, ok := a.pending.pool[ID]
+	if !ok || c.HandlerID != handlerID {
+		return nil, false
+	}
+	delete(a.pending.pool, ID)
+	return c, true
+}
+
+// mainTagAt returns the offsets of the MainTag's opening tag, the end of the
+// opening tag and its closing tag, or -1 if the page has none
+func mainTagAt(page string) (start int, open int, end int) {
+	for i := 0; i < len(page); {
+		j := indexFold(page[i:], "<main")
+		if j < 0 {
+			break
+		}
+		start = i + j
+		n := start + len("<main")
+		if n < len(page) && (isSpace(page[n]) || page[n] == '>' || page[n] == '/') {
+			open = tagEnd(page, start)
+			if k := indexFold(page[open:], "</main"); k >= 0 {
+				return start, open, open + k
+			}
+			break
+		}
+		i = n
+	}
+	return -1, -1, -1
+}
@@ -0,0 +1,142 @@
+package fncmp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ssrPage serves a page rendering hf into its MainTag with Config.SSR
+func ssrPage(t *testing.T, app *App, hf HandleFn) (h *handler, body string) {
+	t.Helper()
+	page := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html><body><main class=\"app\"></main></body></html>")
+	}
+	mw := app.MiddleWareFn(page, hf)
+	rec := httptest.NewRecorder()
+	mw(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	handlers := app.handlers.All()
+	if len(handlers) != 1 {
+		t.Fatalf("%d handlers, want 1", len(handlers))
+	}
+	h = &handlers[0]
+	t.Cleanup(h.stop)
+	return h, rec.Body.String()
+}
+
+var ssrConnID = regexp.MustCompile(`<main data-fncmp-conn="([^"]+)" class="app">`)
+
+func TestServerRenderHydrate(t *testing.T) {
+	app := NewApp(&Config{Silent: true, SSR: true})
+	h, body := ssrPage(t, app, clickCounter)
+	m := ssrConnID.FindStringSubmatch(body)
+	if m == nil || !strings.Contains(body, "button") {
+		t.Fatalf("page not rendered: %s", body)
+	}
+
+	c, ok := app.claim(m[1], h.id)
+	if !ok {
+		t.Fatal("pending conn not found")
+	}
+	tr := newMemTransport()
+	app.attach(c, tr, httptest.NewRequest(http.MethodGet, "/", nil))
+	tc := connectTestClient(t, c, tr)
+
+	// The client hydrates the page instead of receiving the root render
+	tc.quiet(100 * time.Millisecond)
+	tc.fire(tc.listener(OnClick))
+	if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "clicked") {
+		t.Errorf("render = %s", d.FnRender.HTML)
+	}
+}
+
+func TestServerRenderUnclaimed(t *testing.T) {
+	app := NewApp(&Config{Silent: true, SSR: true})
+	ctxs := make(chan context.Context, 1)
+	_, body := ssrPage(t, app, func(ctx context.Context) FnComponent {
+		ctxs <- ctx
+		return clickCounter(ctx)
+	})
+	ctx := <-ctxs
+	id := ssrConnID.FindStringSubmatch(body)[1]
+	c, ok := app.pending.Get(id)
+	if !ok {
+		t.Fatal("pending conn not found")
+	}
+
+	app.expire(c)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context of an expired conn not cancelled")
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Error("expired conn not done")
+	}
+	if n := len(app.listeners.List(c)); n != 0 {
+		t.Errorf("expired conn has %d listeners", n)
+	}
+}
+
+func TestServerRenderOutsideMain(t *testing.T) {
+	app := NewApp(&Config{Silent: true, SSR: true})
+	ctxs := make(chan context.Context, 1)
+	_, body := ssrPage(t, app, func(ctx context.Context) FnComponent {
+		ctxs <- ctx
+		return NewFn(ctx, HTML("elsewhere")).SwapElementInner("other")
+	})
+	if strings.Contains(body, "elsewhere") || strings.Contains(body, ssrAttr) {
+		t.Errorf("render outside the MainTag was server rendered: %s", body)
+	}
+	select {
+	case <-(<-ctxs).Done():
+	case <-time.After(time.Second):
+		t.Fatal("context of a discarded render not cancelled")
+	}
+}
+
+func TestServerRenderMaxPending(t *testing.T) {
+	app := NewApp(&Config{Silent: true, SSR: true, MaxPending: 2})
+	page := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html><body><main class=\"app\"></main></body></html>")
+	}
+	mw := app.MiddleWareFn(page, clickCounter)
+	t.Cleanup(func() {
+		for _, h := range app.handlers.All() {
+			h.stop()
+		}
+	})
+	get := func() string {
+		rec := httptest.NewRecorder()
+		mw(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Body.String()
+	}
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		m := ssrConnID.FindStringSubmatch(get())
+		if m == nil {
+			t.Fatalf("page %d not server rendered", i)
+		}
+		ids = append(ids, m[1])
+	}
+	if body := get(); strings.Contains(body, ssrAttr) || strings.Contains(body, "button") {
+		t.Errorf("page over MaxPending was server rendered: %s", body)
+	}
+	if n := app.pending.Len(); n != 2 {
+		t.Errorf("%d pending conns, want 2", n)
+	}
+
+	c, _ := app.pending.Get(ids[0])
+	app.expire(c)
+	if body := get(); !strings.Contains(body, ssrAttr) {
+		t.Errorf("page not server rendered once a pending conn expired: %s", body)
+	}
+}
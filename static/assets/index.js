@@ -2,14 +2,31 @@
 let conn_id = undefined;
 let base_url = undefined;
 let verbose = false;
+// PROTOCOL_VERSION must match fncmp.ProtocolVersion on the server
+const PROTOCOL_VERSION = 1;
+// build_id is the server's BuildID, only set when the server is in DevMode
+let build_id = undefined;
+const RECONNECT_DELAY = 1000;
+// AWAIT_TIMEOUT is how long a render is held waiting for its target
+const AWAIT_TIMEOUT = 10000;
+// hydrate_root is the MainTag of a page rendered by the server, whose event
+// listeners are attached once the connection is established
+let hydrate_root = document.querySelector("[data-fncmp-conn]");
+// registry holds the only functions the server may run with FnCustom, it is
+// a Map so inherited properties such as "constructor" are never looked up
+const registry = new Map();
+window.fncmp = {
+    register: (name, fn) => {
+        registry.set(name, fn);
+    },
+};
 class Socket {
     constructor() {
         this.ws = null;
         this.addr = undefined;
+        this.path = undefined;
         this.key = undefined;
-        if (this.addr) {
-            throw new Error("ws: already connected to server...");
-        }
+        this.opened = false;
         let key = localStorage.getItem("fncmp_key");
         if (!key) {
             key = "xxxxxxxx-xxxx-4xxx-yxxx-xxxxxxxxxxxx".replace(/[xy]/g, function (c) {
@@ -19,6 +36,10 @@ class Socket {
             localStorage.setItem("fncmp_key", key);
         }
         this.key = key;
+        // The server rendered the page for a connection with its own ID
+        if (hydrate_root) {
+            this.key = hydrate_root.getAttribute("data-fncmp-conn");
+        }
         let path = window.location.pathname.split("");
         let path_parsed = "";
         if (path[-1] == "/" || (path.length == 1 && path[0] == "/")) {
@@ -28,6 +49,7 @@ class Socket {
         if (path_parsed == "") {
             path_parsed = "/main";
         }
+        this.path = path_parsed;
         this.addr = "ws://" + window.location.host + path_parsed + "?fncmp_id=" + this.key;
         this.connect();
     }
@@ -36,22 +58,70 @@ class Socket {
             this.ws = new WebSocket(this.addr);
         }
         catch (_a) {
-            throw new Error("ws: failed to connect to server...");
+            this.fallback();
+            return;
         }
-        this.ws.onopen = function () {
-            document.getElementById('fncmp_script').remove();
+        this.ws.onopen = () => {
+            this.opened = true;
+            this.ws.send(JSON.stringify(Handshake(["binary"])));
+        };
+        this.ws.onclose = () => {
+            // In DevMode, keep reconnecting until the server is back
+            if (build_id) {
+                console.warn("fncmp: connection lost, reconnecting");
+                setTimeout(() => this.connect(), RECONNECT_DELAY);
+                return;
+            }
+            // Proxies that strip the upgrade fail the socket before it opens
+            if (!this.opened) {
+                this.fallback();
+            }
         };
-        this.ws.onclose = function () { };
-        this.ws.onerror = function (e) { };
+        this.ws.onerror = function () { };
+        this.ws.binaryType = "arraybuffer";
         this.ws.onmessage = function (event) {
-            let d = JSON.parse(event.data);
+            let d;
+            if (typeof event.data === "string") {
+                d = JSON.parse(event.data);
+            }
+            else {
+                d = DecodeFrame(event.data);
+            }
             api.Process(this, d);
         };
     }
+    // fallback receives dispatches as Server-Sent Events and sends them with HTTP POST
+    fallback() {
+        const url = this.path + "?fncmp_id=" + this.key + "&fncmp_transport=sse";
+        const sender = {
+            send: (data) => {
+                fetch(url, {
+                    method: "POST",
+                    headers: { "Content-Type": "application/json" },
+                    body: data,
+                });
+            },
+        };
+        const source = new EventSource(url);
+        // Server-Sent Events only carry text, so binary frames are not supported
+        source.onopen = () => sender.send(JSON.stringify(Handshake([])));
+        source.onmessage = function (event) {
+            api.Process(sender, JSON.parse(event.data));
+        };
+        // Sent by the server when it shuts down, otherwise EventSource reconnects.
+        // In DevMode it is left to reconnect to the restarted server.
+        source.addEventListener("close", () => {
+            if (!build_id)
+                source.close();
+        });
+    }
 }
 class API {
     constructor() {
         this.ws = null;
+        this.features = [];
+        // awaiting are renders held until their target is rendered
+        this.awaiting = [];
         this.Dispatch = (data) => {
             if (!data)
                 return;
@@ -61,9 +131,21 @@ class API {
             this.ws.send(JSON.stringify(data));
         };
         this.funs = {
-            initialize: (d) => {
-                d = this.utils.parseEventListeners(document.body, d);
-                this.Dispatch(this.utils.addEventListeners(d));
+            custom: (d) => {
+                const fn = registry.get(d.custom.function);
+                if (!fn) {
+                    return this.Error(d, "function not registered: " + d.custom.function);
+                }
+                Promise.resolve()
+                    .then(() => fn(d.custom.data))
+                    .then((result) => {
+                    // Only JSFunc calls wait for a result
+                    if (!d.id)
+                        return;
+                    d.custom.result = result === undefined ? null : result;
+                    this.Dispatch(d);
+                })
+                    .catch((err) => this.Error(d, String(err)));
             },
             render: (d) => {
                 let elem = null;
@@ -74,9 +156,18 @@ class API {
                     if (!elem) {
                         return this.Error(d, "element with tag not found: " + d.render.tag);
                     }
+                    // A new page replaces the targets held renders are waiting for
+                    if (d.render.tag == "main" && d.render.inner) {
+                        this.awaiting = [];
+                    }
                 }
                 else if (d.render.target_id != "") {
                     elem = document.getElementById(d.render.target_id);
+                    if (!elem && d.render.await) {
+                        this.awaiting.push({ d: d, until: Date.now() + AWAIT_TIMEOUT });
+                        setTimeout(() => this.utils.retryAwaiting(), AWAIT_TIMEOUT);
+                        return;
+                    }
                     if (!elem) {
                         return this.Error(d, "element with target_id not found: " +
                             d.render.target_id);
@@ -97,10 +188,52 @@ class API {
                 if (d.render.prepend) {
                     elem.innerHTML = html + elem.innerHTML;
                 }
+                this.utils.measure(d);
                 d = this.utils.parseEventListeners(elem, d);
                 this.Dispatch(this.utils.addEventListeners(d));
+                this.utils.retryAwaiting();
                 return;
             },
+            // keyed changes a single item of a KeyedList, leaving its siblings untouched
+            keyed: (d) => {
+                const list = document.getElementById(d.render.target_id);
+                if (!list) {
+                    return this.Error(d, "element with target_id not found: " + d.render.target_id);
+                }
+                const current = this.utils.keyedItem(list, d.keyed.key);
+                let item = null;
+                switch (d.keyed.op) {
+                    case "insert":
+                        item = this.utils.parseItem(d.render.html);
+                        if (current)
+                            current.remove();
+                        this.utils.insertAt(list, item, d.keyed.index);
+                        break;
+                    case "update":
+                        if (!current) {
+                            return this.Error(d, "item with key not found: " + d.keyed.key);
+                        }
+                        item = this.utils.parseItem(d.render.html);
+                        current.replaceWith(item);
+                        break;
+                    case "move":
+                        if (!current) {
+                            return this.Error(d, "item with key not found: " + d.keyed.key);
+                        }
+                        this.utils.insertAt(list, current, d.keyed.index);
+                        break;
+                    case "remove":
+                        if (current)
+                            current.remove();
+                        break;
+                }
+                this.utils.measure(d);
+                if (!item)
+                    return;
+                d = this.utils.parseEventListeners(item, d);
+                this.Dispatch(this.utils.addEventListeners(d));
+                this.utils.retryAwaiting();
+            },
         };
         this.utils = {
             parseEventListeners: (element, d) => {
@@ -133,6 +266,69 @@ class API {
                     elem.classList.remove("touch");
                 });
             },
+            // observeVisible dispatches the synthetic visible event once the element enters the viewport
+            observeVisible: (elem, d, listener) => {
+                const observer = new IntersectionObserver((entries) => {
+                    if (!entries.some((e) => e.isIntersecting))
+                        return;
+                    observer.disconnect();
+                    d.function = "event";
+                    d.event = listener;
+                    d.event.data = ParseEventTarget(elem);
+                    d.trace_id = TraceID();
+                    performance.mark("fncmp:" + d.trace_id);
+                    this.Dispatch(d);
+                });
+                // A text placeholder is observed through its wrapper
+                observer.observe(elem instanceof Element ? elem : elem.parentElement);
+            },
+            // keyedItem returns the item of a KeyedList with key
+            keyedItem: (list, key) => {
+                return list.querySelector(`:scope > [data-fncmp-key="${CSS.escape(key)}"]`);
+            },
+            // parseItem parses a KeyedList item in a template, so items such as table rows keep their tag
+            parseItem: (html) => {
+                const template = document.createElement("template");
+                template.innerHTML = html;
+                const item = template.content.firstElementChild;
+                return item;
+            },
+            // insertAt inserts an item before the keyed item at index, or at the end of the list
+            insertAt: (list, item, index) => {
+                const items = Array.from(list.querySelectorAll(":scope > [data-fncmp-key]"))
+                    .filter((el) => el !== item);
+                const next = index >= 0 && index < items.length ? items[index] : null;
+                list.insertBefore(item, next);
+            },
+            // retryAwaiting renders the held renders whose target now exists,
+            // and drops those that have expired
+            retryAwaiting: () => {
+                const now = Date.now();
+                const awaiting = this.awaiting;
+                this.awaiting = [];
+                awaiting.forEach((a) => {
+                    if (document.getElementById(a.d.render.target_id)) {
+                        this.Dispatch(this.funs.render(a.d));
+                    }
+                    else if (a.until > now) {
+                        this.awaiting.push(a);
+                    }
+                    else {
+                        this.Error(a.d, "element with target_id not found: " +
+                            a.d.render.target_id);
+                    }
+                });
+            },
+            // measure records the time from an event to the first render it caused
+            measure: (d) => {
+                if (!d.trace_id)
+                    return;
+                const mark = "fncmp:" + d.trace_id;
+                if (performance.getEntriesByName(mark).length == 0)
+                    return;
+                performance.measure("fncmp " + d.trace_id, mark);
+                performance.clearMarks(mark);
+            },
             getAttributes: (elem, attribute) => {
                 const elems = elem.querySelectorAll(`[${attribute}]`);
                 return Array.from(elems).map((el) => el.getAttribute(attribute));
@@ -150,6 +346,10 @@ class API {
                     if (elem.firstChild) {
                         elem = elem.firstChild;
                     }
+                    if (listener.on == "visible") {
+                        this.utils.observeVisible(elem, d, listener);
+                        return;
+                    }
                     elem.addEventListener(listener.on, (ev) => {
                         ev.preventDefault();
                         d.function = "event";
@@ -179,6 +379,9 @@ class API {
                             default:
                                 d.event.data = ParseEventTarget(ev.target);
                         }
+                        // The server traces the event and its renders under this ID
+                        d.trace_id = TraceID();
+                        performance.mark("fncmp:" + d.trace_id);
                         this.Dispatch(d);
                     });
                 });
@@ -189,28 +392,95 @@ class API {
             d.error.message = message;
             this.Dispatch(d);
         };
+        // The server pauses timers while the page is hidden
+        document.addEventListener("visibilitychange", () => {
+            if (this.ws)
+                this.ws.send(JSON.stringify(Visibility()));
+        });
     }
     Process(ws, d) {
         if (!this.ws) {
             this.ws = ws;
         }
         switch (d.function) {
-            case "initialize":
-                this.Dispatch(this.funs.initialize(d));
+            case "handshake":
+                if (d.handshake.version != PROTOCOL_VERSION) {
+                    console.error("fncmp: server speaks protocol version " +
+                        d.handshake.version +
+                        ", client speaks " +
+                        PROTOCOL_VERSION);
+                    return;
+                }
+                // A reconnected client sends through the new connection
+                this.ws = ws;
+                this.features = d.handshake.features || [];
+                if (this.features.includes("reload")) {
+                    // The server was restarted, possibly with new code
+                    if (build_id && build_id != d.handshake.build_id) {
+                        window.location.reload();
+                        return;
+                    }
+                    build_id = d.handshake.build_id;
+                }
+                if (hydrate_root) {
+                    // Attach listeners to the server rendered markup instead of re-rendering it
+                    const root = hydrate_root;
+                    hydrate_root = null;
+                    root.removeAttribute("data-fncmp-conn");
+                    this.Dispatch(this.utils.addEventListeners(this.utils.parseEventListeners(root, d)));
+                }
+                if (document.hidden) {
+                    ws.send(JSON.stringify(Visibility()));
+                }
+                return;
+            case "error":
+                console.error("fncmp: " + d.error.message);
                 return;
             case "redirect":
                 window.location.href = d.redirect.url;
                 return;
             case "custom":
-                this.Dispatch(window[d.custom.function](d.custom.data));
+                this.funs.custom(d);
                 return;
             case "render":
                 this.Dispatch(this.funs.render(d));
+                return;
+            case "keyed":
+                this.Dispatch(this.funs.keyed(d));
+                return;
             default:
                 break;
         }
     }
 }
+// Handshake is the first dispatch sent on every connection
+function Handshake(capabilities) {
+    return {
+        function: "handshake",
+        handshake: { version: PROTOCOL_VERSION, capabilities: capabilities },
+    };
+}
+// Visibility reports whether the page is hidden
+function Visibility() {
+    return {
+        function: "visibility",
+        visibility: { hidden: document.hidden },
+    };
+}
+// TraceID returns a random 16 byte trace ID in hex, as used by W3C Trace Context
+function TraceID() {
+    const b = crypto.getRandomValues(new Uint8Array(16));
+    return Array.from(b, (v) => v.toString(16).padStart(2, "0")).join("");
+}
+// DecodeFrame decodes a binary frame: a 4 byte big-endian header length,
+// the JSON dispatch header, and the raw rendered HTML.
+function DecodeFrame(buf) {
+    const decoder = new TextDecoder();
+    const size = new DataView(buf).getUint32(0);
+    const d = JSON.parse(decoder.decode(new Uint8Array(buf, 4, size)));
+    d.render.html = decoder.decode(new Uint8Array(buf, 4 + size));
+    return d;
+}
 function ParseEventTarget(ev) {
     return {
         id: ev.id || "",
@@ -0,0 +1,128 @@
+package fncmp
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Suspense renders a placeholder in place of a slow component, and streams the
+// component into it once loaded:
+//
+//	NewFn(ctx, Suspense{
+//		Fallback: HTML("<p>Loading...</p>"),
+//		Load: func(ctx context.Context) (Component, error) {
+//			orders, err := db.Orders(ctx)
+//			return OrderList(orders), err
+//		},
+//		Timeout: 5 * time.Second,
+//		Error: func(err error) Component { return Text("Orders are unavailable") },
+//	})
+//
+// Load runs in a goroutine that is cancelled when the connection closes. Each
+// Render starts loading the component again. Without a connection, such as
+// when rendered by RenderComponent, Load is called before Render returns and
+// Render returns its error.
+type Suspense struct {
+	// Fallback is rendered until Load returns
+	Fallback Component
+	// Load returns the slow component, ctx is cancelled on timeout or when the connection closes
+	Load func(ctx context.Context) (Component, error)
+	// Timeout bounds Load, 0 for no timeout
+	Timeout time.Duration
+	// Error is rendered in place of the Fallback if Load fails or times out,
+	// the Fallback is kept if nil
+	Error func(err error) Component
+}
+
+// Render renders the Fallback and starts loading the component
+func (s Suspense) Render(ctx context.Context, w io.Writer) error {
+	dd, ok := ctx.Value(dispatchKey).(dispatchDetails)
+	if !ok || dd.Conn == nil {
+		c, err := s.load(ctx, nil)
+		if c == nil {
+			c = s.Fallback
+		}
+		if rerr := renderOptional(ctx, w, c); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+
+	id := "fncmp-suspense-" + uuid.New().String()
+	io.WriteString(w, "<div"+attr("id", id)+">")
+	renderOptional(ctx, w, s.Fallback)
+	io.WriteString(w, "</div>")
+
+	go func() {
+		c, err := s.load(ctx, dd.Conn.done)
+		select {
+		case <-dd.Conn.done:
+			return
+		default:
+		}
+		if err != nil {
+			dd.Conn.app.log.Warn("suspended component failed", dd.Conn.logFields("target", id, "error", err)...)
+		}
+		if c == nil {
+			return
+		}
+		fn := NewFn(ctx, c).SwapElementOuter(id)
+		// The placeholder may still be on its way to the client
+		fn.dispatch.FnRender.Await = true
+		fn.Dispatch()
+	}()
+	return nil
+}
+
+// load calls Load with the Suspense's timeout, returning the component to
+// render in place of the Fallback, or nil to keep it
+func (s Suspense) load(ctx context.Context, done <-chan struct{}) (Component, error) {
+	if s.Load == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if s.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		c   Component
+		err error
+	}
+	loaded := make(chan result, 1)
+	go func() {
+		c, err := s.Load(ctx)
+		loaded <- result{c, err}
+	}()
+
+	var r result
+	select {
+	case r = <-loaded:
+	case <-ctx.Done():
+		r.err = ctx.Err()
+		if r.err == context.DeadlineExceeded {
+			r.err = ErrSuspenseTimeout
+		}
+	case <-done:
+		return nil, ErrConnectionClosed
+	}
+	if r.err == nil {
+		return r.c, nil
+	}
+	if s.Error == nil {
+		return nil, r.err
+	}
+	return s.Error(r.err), r.err
+}
+
+func renderOptional(ctx context.Context, w io.Writer, c Component) error {
+	if c == nil {
+		return nil
+	}
+	return c.Render(ctx, w)
+}
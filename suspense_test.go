@@ -0,0 +1,137 @@
+package fncmp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuspenseWithoutConn(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name     string
+		suspense Suspense
+		want     string
+		err      error
+	}{
+		{
+			name: "loaded",
+			suspense: Suspense{Load: func(ctx context.Context) (Component, error) {
+				return HTML("loaded"), nil
+			}},
+			want: "loaded",
+		},
+		{
+			name: "error",
+			suspense: Suspense{
+				Load:  func(ctx context.Context) (Component, error) { return nil, failed },
+				Error: func(err error) Component { return HTML("error: " + err.Error()) },
+			},
+			want: "error: failed",
+			err:  failed,
+		},
+		{
+			name: "error keeps fallback",
+			suspense: Suspense{
+				Load: func(ctx context.Context) (Component, error) { return nil, failed },
+			},
+			want: "loading",
+			err:  failed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.suspense.Fallback = HTML("loading")
+			var w Writer
+			err := tt.suspense.Render(context.Background(), &w)
+			if err != tt.err {
+				t.Errorf("Render error = %v, want %v", err, tt.err)
+			}
+			if got := string(w.buf); got != tt.want {
+				t.Errorf("rendered %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuspenseSwap(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	release := make(chan struct{})
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		return NewFn(ctx, Suspense{
+			Fallback: HTML("loading"),
+			Load: func(ctx context.Context) (Component, error) {
+				<-release
+				return HTML("loaded"), nil
+			},
+		})
+	})
+	if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "loading") {
+		t.Fatalf("root render = %q, want the fallback", d.FnRender.HTML)
+	}
+	close(release)
+	d := tc.nextRender()
+	if !d.FnRender.Outer || !d.FnRender.Await || !strings.HasPrefix(d.FnRender.TargetID, "fncmp-suspense-") {
+		t.Errorf("swap = %+v, want an awaited outer swap of the placeholder", d.FnRender)
+	}
+	if !strings.Contains(d.FnRender.HTML, "loaded") {
+		t.Errorf("swap = %q, want loaded", d.FnRender.HTML)
+	}
+}
+
+func TestSuspenseFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		load    func(ctx context.Context) (Component, error)
+		timeout time.Duration
+		error   bool
+		want    string
+	}{
+		{
+			name: "timeout",
+			load: func(ctx context.Context) (Component, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			timeout: 10 * time.Millisecond,
+			error:   true,
+			want:    ErrSuspenseTimeout.Error(),
+		},
+		{
+			name: "error",
+			load: func(ctx context.Context) (Component, error) {
+				return nil, errors.New("failed")
+			},
+			error: true,
+			want:  "failed",
+		},
+		{
+			name: "error keeps fallback",
+			load: func(ctx context.Context) (Component, error) {
+				return nil, errors.New("failed")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Suspense{Fallback: HTML("loading"), Load: tt.load, Timeout: tt.timeout}
+			if tt.error {
+				s.Error = func(err error) Component { return HTML("error: " + err.Error()) }
+			}
+			app := NewApp(&Config{Silent: true})
+			tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+				return NewFn(ctx, s)
+			})
+			tc.nextRender()
+			if tt.want == "" {
+				tc.quiet(100 * time.Millisecond)
+				return
+			}
+			if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "error: "+tt.want) {
+				t.Errorf("swap = %q, want error: %s", d.FnRender.HTML, tt.want)
+			}
+		})
+	}
+}
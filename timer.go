@@ -0,0 +1,160 @@
+package fncmp
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Every calls hf every interval and dispatches the FnComponent it returns,
+// until stop is called or the connection closes:
+//
+//	stop, err := fncmp.Every(ctx, 5*time.Second, func(ctx context.Context) fncmp.FnComponent {
+//		return fncmp.NewFn(ctx, Stats(stats.Latest())).SwapElementInner("stats")
+//	})
+//
+// hf is run by the connection's worker, in turn with its event listeners. Calls
+// are paused while the client's page is hidden, the next is made as soon as it
+// is shown again. Delays are randomized by Config.TimerJitter.
+//
+// Timers started while server rendering first call hf once the client connects,
+// and stop if it never does. ErrNoClientConnection is returned if ctx has no
+// connection.
+func Every(ctx context.Context, interval time.Duration, hf HandleFn) (stop func(), err error) {
+	if interval <= 0 {
+		panic("fncmp: non-positive interval for Every")
+	}
+	return schedule(ctx, interval, true, hf)
+}
+
+// After calls hf once after delay and dispatches the FnComponent it returns,
+// unless stop is called or the connection closes first.
+//
+// If the client's page is hidden after delay, hf is called once it is shown.
+// The delay is randomized by Config.TimerJitter. ErrNoClientConnection is
+// returned if ctx has no connection.
+func After(ctx context.Context, delay time.Duration, hf HandleFn) (stop func(), err error) {
+	return schedule(ctx, delay, false, hf)
+}
+
+// schedule runs a timer bound to the conn of ctx
+func schedule(ctx context.Context, delay time.Duration, repeat bool, hf HandleFn) (stop func(), err error) {
+	dd, ok := ctx.Value(dispatchKey).(dispatchDetails)
+	if !ok || dd.Conn == nil {
+		return func() {}, ErrNoClientConnection
+	}
+	c := dd.Conn
+	label := "after"
+	if repeat {
+		label = "every"
+	}
+
+	ctx, stop = context.WithCancel(ctx)
+	go func() {
+		defer stop()
+		for {
+			t := time.NewTimer(c.app.jitter(delay))
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-c.done:
+				t.Stop()
+				return
+			}
+			select {
+			case <-c.visible.wait():
+			case <-ctx.Done():
+				return
+			case <-c.done:
+				return
+			}
+
+			// sent reports whether the job's FnComponent was queued for the client
+			sent := make(chan bool, 1)
+			job := func() {
+				if ctx.Err() != nil {
+					sent <- false
+					return
+				}
+				start := time.Now()
+				fn := hf(ctx)
+				elapsed := time.Since(start)
+				c.app.observe(func(hooks Hooks) { hooks.Handled("timer", label, elapsed) })
+				fn.dispatch.conn = c
+				fn.dispatch.HandlerID = c.HandlerID
+				h, ok := c.app.handlers.Get(c.HandlerID)
+				sent <- ok && h.send(fn)
+			}
+			if !c.run(job) {
+				c.app.log.Warn("too many jobs queued, skipping timer", c.logFields("timer", label)...)
+				c.app.observe(func(hooks Hooks) { hooks.Error(errorKindOverflow) })
+				continue
+			}
+			select {
+			case ok := <-sent:
+				if !ok || !repeat {
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-c.done:
+				return
+			case <-c.worked:
+				return
+			}
+		}
+	}()
+	return stop, nil
+}
+
+// jitter randomizes d by up to Config.TimerJitter of it
+func (a *App) jitter(d time.Duration) time.Duration {
+	j := a.config.TimerJitter
+	if j <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*j*float64(d))
+}
+
+// pageVisibility tracks whether the client's page is visible, which it is
+// until the client reports otherwise
+type pageVisibility struct {
+	mu     sync.Mutex
+	hidden bool
+	// shown is closed when the page is shown again
+	shown chan struct{}
+}
+
+func (v *pageVisibility) set(hidden bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if hidden == v.hidden {
+		return
+	}
+	v.hidden = hidden
+	if hidden {
+		v.shown = make(chan struct{})
+	} else {
+		close(v.shown)
+	}
+}
+
+// wait returns a channel that is closed once the page is visible
+func (v *pageVisibility) wait() <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.hidden {
+		return closedChan
+	}
+	return v.shown
+}
+
+// closedChan is a channel that is always closed
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
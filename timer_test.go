@@ -0,0 +1,196 @@
+package fncmp
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ticker returns a HandleFn rendering "tick", counting its calls in n
+func ticker(n *atomic.Int64) HandleFn {
+	return func(ctx context.Context) FnComponent {
+		n.Add(1)
+		return NewFn(ctx, HTML("tick"))
+	}
+}
+
+// settled fails the test unless n stops changing
+func settled(t *testing.T, n *atomic.Int64) {
+	t.Helper()
+	time.Sleep(50 * time.Millisecond)
+	before := n.Load()
+	time.Sleep(100 * time.Millisecond)
+	if after := n.Load(); after != before {
+		t.Fatalf("timer still running: %d calls, then %d", before, after)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	stops := make(chan func(), 1)
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		stop, err := Every(ctx, 10*time.Millisecond, func(ctx context.Context) FnComponent {
+			return NewFn(ctx, HTML("tick"))
+		})
+		if err != nil {
+			t.Errorf("Every() error = %v", err)
+		}
+		stops <- stop
+		return NewFn(ctx, HTML("root"))
+	})
+	tc.nextRender()
+	for i := 0; i < 2; i++ {
+		if d := tc.nextRender(); !strings.Contains(d.FnRender.HTML, "tick") {
+			t.Fatalf("render = %q, want a tick", d.FnRender.HTML)
+		}
+	}
+
+	// A tick may already be running when stop is called
+	(<-stops)()
+	time.Sleep(50 * time.Millisecond)
+	for len(tc.out) > 0 {
+		<-tc.out
+	}
+	tc.quiet(100 * time.Millisecond)
+}
+
+func TestEveryPausedWhileHidden(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	var n atomic.Int64
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		Every(ctx, 10*time.Millisecond, ticker(&n))
+		return NewFn(ctx, HTML("root"))
+	})
+	tc.nextRender()
+	tc.nextRender()
+
+	hidden := newDispatch("fncmp-visibility")
+	hidden.Function = visibility
+	hidden.FnVisibility.Hidden = true
+	tc.send(*hidden)
+	settled(t, &n)
+
+	shown := newDispatch("fncmp-visibility")
+	shown.Function = visibility
+	tc.send(*shown)
+	before := n.Load()
+	for len(tc.out) > 0 {
+		<-tc.out
+	}
+	tc.nextRender()
+	if n.Load() == before {
+		t.Error("timer not resumed when the page was shown")
+	}
+}
+
+func TestTimerStopsOnClose(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	var n atomic.Int64
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		Every(ctx, 10*time.Millisecond, ticker(&n))
+		return NewFn(ctx, HTML("root"))
+	})
+	tc.nextRender()
+	tc.nextRender()
+	tc.conn.close()
+	settled(t, &n)
+}
+
+func TestTimerStopsUnclaimedServerRender(t *testing.T) {
+	app := NewApp(&Config{Silent: true, SSR: true})
+	var n atomic.Int64
+	_, body := ssrPage(t, app, func(ctx context.Context) FnComponent {
+		Every(ctx, 10*time.Millisecond, ticker(&n))
+		return NewFn(ctx, HTML("root"))
+	})
+	c, ok := app.pending.Get(ssrConnID.FindStringSubmatch(body)[1])
+	if !ok {
+		t.Fatal("pending conn not found")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n.Load() != 0 {
+		t.Fatal("timer of the pending conn ran before its client connected")
+	}
+
+	app.expire(c)
+	settled(t, &n)
+	if n.Load() != 0 {
+		t.Error("timer of the expired conn ran")
+	}
+}
+
+func TestTimerWithoutConn(t *testing.T) {
+	var n atomic.Int64
+	if _, err := Every(context.Background(), time.Millisecond, ticker(&n)); err != ErrNoClientConnection {
+		t.Errorf("Every() error = %v, want %v", err, ErrNoClientConnection)
+	}
+	if _, err := After(context.Background(), time.Millisecond, ticker(&n)); err != ErrNoClientConnection {
+		t.Errorf("After() error = %v, want %v", err, ErrNoClientConnection)
+	}
+	settled(t, &n)
+	if n.Load() != 0 {
+		t.Error("timer without a connection ran")
+	}
+}
+
+func TestTimerRunsInTurnWithEvents(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	var busy, overlaps, ticks atomic.Int64
+	// job fails the overlap check if another job of the conn is running
+	job := func() {
+		if busy.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(5 * time.Millisecond)
+		busy.Add(-1)
+	}
+	tc := newTestClient(t, newTestHandler(t, app), func(ctx context.Context) FnComponent {
+		Every(ctx, time.Millisecond, func(ctx context.Context) FnComponent {
+			job()
+			ticks.Add(1)
+			return NewFn(ctx, nil)
+		})
+		return NewFn(ctx, HTML("button")).WithEvents(func(ctx context.Context) FnComponent {
+			job()
+			return NewFn(ctx, HTML("clicked"))
+		}, OnClick)
+	})
+	tc.nextRender()
+	el := tc.listener(OnClick)
+	for i := 0; i < 10; i++ {
+		tc.fire(el)
+		tc.nextRender()
+	}
+	if ticks.Load() == 0 {
+		t.Fatal("timer never ran")
+	}
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("timer ran concurrently with an event listener %d times", n)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		jitter   float64
+		min, max time.Duration
+	}{
+		{name: "none", min: time.Second, max: time.Second},
+		{name: "negative", jitter: -1, min: time.Second, max: time.Second},
+		{name: "tenth", jitter: 0.1, min: 900 * time.Millisecond, max: 1100 * time.Millisecond},
+		{name: "one", jitter: 1, min: time.Second, max: time.Second},
+		{name: "over one", jitter: 2, min: time.Second, max: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp(&Config{Silent: true, TimerJitter: tt.jitter})
+			for i := 0; i < 100; i++ {
+				if d := app.jitter(time.Second); d < tt.min || d > tt.max {
+					t.Fatalf("jitter(1s) = %v, want within [%v, %v]", d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
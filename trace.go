@@ -0,0 +1,72 @@
+package fncmp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Tracer starts the spans of an event's round trip, see Config.Tracer.
+//
+// Its shape follows OpenTelemetry's, so an adapter only has to convert
+// keyvals to attributes. The event's trace ID, shared with the client, is
+// available from TraceID(ctx).
+//
+// fncmp starts these spans:
+//
+//	fncmp.event   an inbound event, with its time queued for the handler
+//	fncmp.handle  the event listener's HandleFn
+//	fncmp.render  rendering a component to HTML
+//	fncmp.publish encoding a dispatch and queuing it for the client
+type Tracer interface {
+	Start(ctx context.Context, name string, keyvals ...any) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer
+type Span interface {
+	SetAttributes(keyvals ...any)
+	RecordError(err error)
+	End()
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string, keyvals ...any) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(keyvals ...any) {}
+func (noopSpan) RecordError(err error)        {}
+func (noopSpan) End()                         {}
+
+// tracer returns Config.Tracer, or a Tracer that does nothing if not set
+func (a *App) tracer() Tracer {
+	if a.config.Tracer == nil {
+		return noopTracer{}
+	}
+	return a.config.Tracer
+}
+
+// TraceID returns the trace ID of the event being handled, or "" if there is none
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(TraceKey).(string)
+	return id
+}
+
+// newTraceID returns a random 16 byte trace ID in hex, as used by W3C Trace Context
+func newTraceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validTraceID reports whether a client sent trace ID can be used as is
+func validTraceID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
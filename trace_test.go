@@ -0,0 +1,104 @@
+package fncmp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testSpan is a span recorded by testTracer
+type testSpan struct {
+	name    string
+	parent  string
+	traceID string
+}
+
+type testSpanKey struct{}
+
+// testTracer records the spans started, with the name of their parent
+type testTracer struct {
+	mu    sync.Mutex
+	spans []testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, keyvals ...any) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parent, _ := ctx.Value(testSpanKey{}).(string)
+	t.spans = append(t.spans, testSpan{name: name, parent: parent, traceID: TraceID(ctx)})
+	return context.WithValue(ctx, testSpanKey{}, name), noopSpan{}
+}
+
+// find returns the last span with name
+func (t *testTracer) find(name string) (testSpan, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := len(t.spans) - 1; i >= 0; i-- {
+		if t.spans[i].name == name {
+			return t.spans[i], true
+		}
+	}
+	return testSpan{}, false
+}
+
+func TestTraceEvent(t *testing.T) {
+	valid := strings.Repeat("ab", 16)
+	tests := []struct {
+		name    string
+		traceID string
+		keep    bool
+	}{
+		{name: "valid", traceID: valid, keep: true},
+		{name: "missing", traceID: ""},
+		{name: "short", traceID: "abcd"},
+		{name: "not hex", traceID: strings.Repeat("zz", 16)},
+		{name: "injected", traceID: valid[:30] + "\n\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := &testTracer{}
+			app := NewApp(&Config{Silent: true, Tracer: tracer})
+			tc := newTestClient(t, newTestHandler(t, app), clickCounter)
+			tc.nextRender()
+
+			el := tc.listener(OnClick)
+			d := newDispatch("fncmp-event")
+			d.Function = event
+			d.ConnID = tc.conn.ID
+			d.HandlerID = tc.conn.HandlerID
+			d.FnEvent = el
+			d.TraceID = tt.traceID
+			tc.send(*d)
+			render := tc.nextRender()
+
+			// The render answering the event carries its trace ID
+			if !validTraceID(render.TraceID) {
+				t.Fatalf("render trace ID %q is not valid", render.TraceID)
+			}
+			if tt.keep != (render.TraceID == tt.traceID) {
+				t.Errorf("render trace ID = %q, client sent %q", render.TraceID, tt.traceID)
+			}
+
+			parents := map[string]string{
+				"fncmp.event":   "",
+				"fncmp.handle":  "fncmp.event",
+				"fncmp.render":  "fncmp.handle",
+				"fncmp.publish": "fncmp.render",
+			}
+			for name, parent := range parents {
+				span, ok := tracer.find(name)
+				if !ok {
+					t.Errorf("no %s span", name)
+					continue
+				}
+				if span.parent != parent {
+					t.Errorf("%s span parent = %q, want %q", name, span.parent, parent)
+				}
+				if span.traceID != render.TraceID {
+					t.Errorf("%s span trace ID = %q, want %q", name, span.traceID, render.TraceID)
+				}
+			}
+		})
+	}
+}
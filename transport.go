@@ -0,0 +1,202 @@
+package fncmp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// transport carries messages between a conn and its client.
+//
+// It is implemented by *websocket.Conn and by sseTransport, which is used when
+// a proxy between the client and server strips WebSocket upgrades.
+type transport interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetReadLimit(limit int64)
+	Close() error
+}
+
+const (
+	// transportParam is the query parameter used by the client to select a transport
+	transportParam = "fncmp_transport"
+	// sseTransportName selects Server-Sent Events downstream and HTTP POST upstream
+	sseTransportName = "sse"
+	// sseKeepAlive is the interval of comment frames that keep idle proxies from closing the stream
+	sseKeepAlive = 25 * time.Second
+)
+
+// upgrade creates the transport requested by the client
+func upgrade(w http.ResponseWriter, r *http.Request, config *Config) (transport, error) {
+	if r.URL.Query().Get(transportParam) == sseTransportName {
+		return newSSETransport(w, r)
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			// TODO: check cookie for session id
+			// Set as conn id
+			return true
+			// host := strings.Split(r.Host, ":")[0]
+			// return host == "localhost"
+		},
+		EnableCompression: config.Compression,
+	}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, errors.New("failed to upgrade connection")
+	}
+	// Only applies if the client negotiated permessage-deflate
+	ws.EnableWriteCompression(config.Compression)
+	return ws, nil
+}
+
+// sseTransport streams outbound messages as Server-Sent Events and receives
+// inbound messages from HTTP POST requests delivered by the conn's handler.
+type sseTransport struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	r       *http.Request
+	flusher http.Flusher
+	in      chan []byte
+	limit   int64
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newSSETransport(w http.ResponseWriter, r *http.Request) (*sseTransport, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming not supported by response writer")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering in nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	return &sseTransport{
+		w:       w,
+		r:       r,
+		flusher: flusher,
+		in:      make(chan []byte, 16),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// open sends the stream's headers, the client POSTs its handshake as soon as
+// it receives them, so the conn must be registered first
+func (t *sseTransport) open() {
+	t.mu.Lock()
+	t.w.WriteHeader(http.StatusOK)
+	t.flusher.Flush()
+	t.mu.Unlock()
+
+	r := t.r
+	go func() {
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				t.Close()
+				return
+			case <-t.done:
+				return
+			case <-ticker.C:
+				t.write([]byte(": keep-alive\n\n"))
+			}
+		}
+	}()
+}
+
+func (t *sseTransport) ReadMessage() (int, []byte, error) {
+	select {
+	case msg := <-t.in:
+		return websocket.TextMessage, msg, nil
+	case <-t.done:
+		return 0, nil, &websocket.CloseError{Code: websocket.CloseGoingAway}
+	}
+}
+
+func (t *sseTransport) WriteMessage(messageType int, data []byte) error {
+	if messageType != websocket.TextMessage {
+		return errors.New("sse transport only supports text messages")
+	}
+	// Each line of a multi-line message needs its own data field
+	frame := []byte("data: ")
+	frame = append(frame, bytes.ReplaceAll(data, []byte("\n"), []byte("\ndata: "))...)
+	frame = append(frame, "\n\n"...)
+	return t.write(frame)
+}
+
+func (t *sseTransport) write(p []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-t.done:
+		return ErrConnectionClosed
+	default:
+	}
+	if _, err := t.w.Write(p); err != nil {
+		return err
+	}
+	t.flusher.Flush()
+	return nil
+}
+
+// SetReadLimit limits the size of the bodies of upstream POST requests
+func (t *sseTransport) SetReadLimit(limit int64) {
+	t.limit = limit
+}
+
+func (t *sseTransport) Close() error {
+	t.once.Do(func() {
+		close(t.done)
+	})
+	return nil
+}
+
+// deliver passes a message received over HTTP POST to the conn's reader
+func (t *sseTransport) deliver(msg []byte) error {
+	select {
+	case t.in <- msg:
+		return nil
+	case <-t.done:
+		return ErrConnectionClosed
+	}
+}
+
+// deliverPost handles an upstream HTTP POST for a conn using the sse transport
+func (a *App) deliverPost(w http.ResponseWriter, r *http.Request, id string) {
+	c, ok := a.conns.Get(id)
+	if !ok {
+		http.Error(w, ErrConnectionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	t, ok := c.transport.(*sseTransport)
+	if !ok {
+		http.Error(w, "connection does not use sse transport", http.StatusBadRequest)
+		return
+	}
+	body := r.Body
+	if t.limit > 0 {
+		body = http.MaxBytesReader(w, r.Body, t.limit)
+	}
+	msg, err := io.ReadAll(body)
+	if err != nil {
+		a.log.Warn("rejected message", c.logFields("error", err)...)
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := t.deliver(msg); err != nil {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package fncmp
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEHandshakeAfterOpen(t *testing.T) {
+	app := NewApp(&Config{Silent: true})
+	page := func(w http.ResponseWriter, r *http.Request) {}
+	root := func(ctx context.Context) FnComponent {
+		return NewFn(ctx, HTML("<p>root</p>"))
+	}
+	srv := httptest.NewServer(app.MiddleWareFn(page, root))
+	defer srv.Close()
+
+	url := srv.URL + "/?fncmp_id=sse-conn&fncmp_transport=sse"
+	stream, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if _, ok := app.conns.Get("sse-conn"); !ok {
+		t.Fatal("stream opened before the conn was registered")
+	}
+
+	// The client POSTs its handshake as soon as the stream is open
+	res, err := http.Post(url, "application/json",
+		strings.NewReader(`{"function":"handshake","handshake":{"version":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("handshake POST status = %d, want %d", res.StatusCode, http.StatusNoContent)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(stream.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed before the root render")
+			}
+			if strings.Contains(line, "root") {
+				return
+			}
+		case <-timeout:
+			t.Fatal("root render not received")
+		}
+	}
+}
@@ -1,11 +1,113 @@
 package fncmp
 
 import (
+	"html"
 	"strings"
 )
 
-func sanitizeHTML(html string) string {
-	sanitized := strings.ReplaceAll(html, "\n", "")
-	sanitized = strings.ReplaceAll(sanitized, "\t", "")
-	return sanitized
+// whitespaceTags are elements whose content is rendered as is by minifyHTML
+var whitespaceTags = []string{"pre", "textarea", "script", "style"}
+
+// minifyHTML collapses insignificant whitespace in html to a single space.
+//
+// Tags, comments and the content of whitespace sensitive elements such as
+// <pre>, <textarea>, <script> and <style> are preserved.
+func minifyHTML(html string) string {
+	var b strings.Builder
+	b.Grow(len(html))
+	space := false
+	for i := 0; i < len(html); {
+		c := html[i]
+		if c == '<' {
+			end := len(html)
+			switch {
+			case strings.HasPrefix(html[i:], "<!--"):
+				if j := strings.Index(html[i:], "-->"); j >= 0 {
+					end = i + j + len("-->")
+				}
+			case whitespaceTagAt(html, i) != "":
+				closing := "</" + whitespaceTagAt(html, i)
+				if j := indexFold(html[i:], closing); j >= 0 {
+					end = tagEnd(html, i+j)
+				}
+			default:
+				end = tagEnd(html, i)
+			}
+			b.WriteString(html[i:end])
+			i = end
+			space = false
+			continue
+		}
+		if isSpace(c) {
+			if !space {
+				b.WriteByte(' ')
+				space = true
+			}
+			i++
+			continue
+		}
+		b.WriteByte(c)
+		space = false
+		i++
+	}
+	return b.String()
+}
+
+// minify minifies rendered HTML unless the App preserves whitespace
+func (a *App) minify(html string) string {
+	if a.config.PreserveWhitespace {
+		return html
+	}
+	return minifyHTML(html)
+}
+
+// whitespaceTagAt returns the name of the whitespace sensitive element opened at html[i]
+func whitespaceTagAt(html string, i int) string {
+	for _, name := range whitespaceTags {
+		n := i + 1 + len(name)
+		if n >= len(html) || !strings.EqualFold(html[i+1:n], name) {
+			continue
+		}
+		if isSpace(html[n]) || html[n] == '>' || html[n] == '/' {
+			return name
+		}
+	}
+	return ""
+}
+
+// tagEnd returns the index after the '>' closing the tag opened at html[i],
+// ignoring any '>' in quoted attribute values
+func tagEnd(html string, i int) int {
+	var quote byte
+	for j := i + 1; j < len(html); j++ {
+		switch c := html[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return j + 1
+		}
+	}
+	return len(html)
+}
+
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// attr renders an HTML attribute with its value quoted and escaped
+func attr(name, value string) string {
+	return " " + name + "=\"" + html.EscapeString(value) + "\""
 }
@@ -0,0 +1,30 @@
+package fncmp
+
+import "testing"
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"empty", "", ""},
+		{"collapses whitespace", "<p>\n\t a  b \n</p>", "<p> a b </p>"},
+		{"keeps words apart", "<b>a</b> <i>b</i>", "<b>a</b> <i>b</i>"},
+		{"preserves pre", "<pre>a\n  b</pre>  x", "<pre>a\n  b</pre> x"},
+		{"preserves textarea", "<TEXTAREA rows=2>a\n\nb</textarea>", "<TEXTAREA rows=2>a\n\nb</textarea>"},
+		{"preserves script", "<script>if (a  <  b) {\n}</script>", "<script>if (a  <  b) {\n}</script>"},
+		{"preserves style", "<style>p  {\n}</style>", "<style>p  {\n}</style>"},
+		{"preserves comments", "<!--  a\n b -->  c", "<!--  a\n b --> c"},
+		{"preserves attributes", `<p title="a  >  b">  c</p>`, `<p title="a  >  b"> c</p>`},
+		{"not a whitespace tag", "<preface>a  b</preface>", "<preface>a b</preface>"},
+		{"unclosed pre", "<pre>a  b", "<pre>a  b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := minifyHTML(tt.html); got != tt.want {
+				t.Errorf("minifyHTML(%q) = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}